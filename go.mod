@@ -1,8 +1,21 @@
 module github.com/SENERGY-Platform/influx-wrapper
 
-go 1.17
+go 1.20
 
 require (
 	github.com/julienschmidt/httprouter v1.3.0
 	github.com/orourkedd/influxdb1-client v0.0.0-20190326200226-bd3b72602b58
+	github.com/prometheus/client_golang v1.9.0
+)
+
+require (
+	github.com/beorn7/perks v1.0.1 // indirect
+	github.com/cespare/xxhash/v2 v2.1.1 // indirect
+	github.com/golang/protobuf v1.4.3 // indirect
+	github.com/matttproud/golang_protobuf_extensions v1.0.1 // indirect
+	github.com/prometheus/client_model v0.2.0 // indirect
+	github.com/prometheus/common v0.15.0 // indirect
+	github.com/prometheus/procfs v0.2.0 // indirect
+	golang.org/x/sys v0.0.0-20201214210602-f9fddec55a1e // indirect
+	google.golang.org/protobuf v1.23.0 // indirect
 )
@@ -27,6 +27,7 @@ import (
 	"reflect"
 	"runtime"
 	"sync"
+	"sync/atomic"
 	"time"
 )
 
@@ -35,6 +36,14 @@ var endpoints = []func(router *httprouter.Router, config configuration.Config, i
 //starts http server; if wg is not nil it will be set as done when the server is stopped
 func Start(ctx context.Context, wg *sync.WaitGroup, config configuration.Config, influx *influx.Influx) (err error) {
 	log.Println("start api")
+	for _, duration := range []string{config.QueryTimeout, config.StreamTimeout, config.WriteTimeout, config.ReadyCache, config.ShutdownDelay, config.RetentionPolicyMinDuration, config.RetentionPolicyMaxDuration} {
+		if duration != "" {
+			_, err = time.ParseDuration(duration)
+			if err != nil {
+				return err
+			}
+		}
+	}
 	router := Router(config, influx)
 	server := &http.Server{Addr: ":" + config.ApiPort, Handler: router, WriteTimeout: 10 * time.Second, ReadTimeout: 2 * time.Second, ReadHeaderTimeout: 2 * time.Second}
 	wg.Add(1)
@@ -47,19 +56,49 @@ func Start(ctx context.Context, wg *sync.WaitGroup, config configuration.Config,
 	}()
 	go func() {
 		<-ctx.Done()
+		atomic.StoreInt32(&draining, 1)
+		shutdownDelay, err := time.ParseDuration(config.ShutdownDelay)
+		if err == nil && shutdownDelay > 0 {
+			log.Println("wait " + shutdownDelay.String() + " before api shutdown")
+			time.Sleep(shutdownDelay)
+		}
 		log.Println("DEBUG: api shutdown", server.Shutdown(context.Background()))
 		wg.Done()
 	}()
 	return nil
 }
 
+// returns a context for influx queries, which is cancelled if the client disconnects or the configured query timeout is exceeded
+func queryContext(config configuration.Config, request *http.Request) (context.Context, context.CancelFunc) {
+	return contextWithTimeout(request, config.QueryTimeout)
+}
+
+// returns a context for streamed responses, which is cancelled if the client disconnects or the configured stream timeout is exceeded
+func streamContext(config configuration.Config, request *http.Request) (context.Context, context.CancelFunc) {
+	return contextWithTimeout(request, config.StreamTimeout)
+}
+
+// returns a context for writes, which is cancelled if the client disconnects or the configured write timeout is exceeded
+func writeContext(config configuration.Config, request *http.Request) (context.Context, context.CancelFunc) {
+	return contextWithTimeout(request, config.WriteTimeout)
+}
+
+func contextWithTimeout(request *http.Request, duration string) (context.Context, context.CancelFunc) {
+	timeout, err := time.ParseDuration(duration)
+	if err != nil || timeout <= 0 {
+		return context.WithCancel(request.Context())
+	}
+	return context.WithTimeout(request.Context(), timeout)
+}
+
 func Router(config configuration.Config, influx *influx.Influx) http.Handler {
 	router := httprouter.New()
 	for _, e := range endpoints {
 		log.Println("add endpoints: " + runtime.FuncForPC(reflect.ValueOf(e).Pointer()).Name())
 		e(router, config, influx)
 	}
-	log.Println("add logging and cors")
+	log.Println("add logging, metrics and cors")
 	corsHandler := util.NewCors(router)
-	return util.NewLogger(corsHandler)
+	metricsHandler := util.NewMetrics(corsHandler, routeResolver(router))
+	return util.NewLogger(metricsHandler)
 }
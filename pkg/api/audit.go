@@ -0,0 +1,66 @@
+/*
+ *    Copyright 2021 InfAI (CC SES)
+ *
+ *    Licensed under the Apache License, Version 2.0 (the "License");
+ *    you may not use this file except in compliance with the License.
+ *    You may obtain a copy of the License at
+ *
+ *        http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *    Unless required by applicable law or agreed to in writing, software
+ *    distributed under the License is distributed on an "AS IS" BASIS,
+ *    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *    See the License for the specific language governing permissions and
+ *    limitations under the License.
+ */
+
+package api
+
+import (
+	"encoding/json"
+	"github.com/SENERGY-Platform/influx-wrapper/pkg/configuration"
+	"log"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+)
+
+// auditEntry is a line of the audit log, which is written for each destructive call
+type auditEntry struct {
+	Time        time.Time `json:"time"`
+	Action      string    `json:"action"`
+	User        string    `json:"user"`
+	Measurement string    `json:"measurement,omitempty"`
+	Statement   string    `json:"statement,omitempty"`
+	RemoteAddr  string    `json:"remoteAddr"`
+	Error       string    `json:"error,omitempty"`
+}
+
+var auditLogger *log.Logger
+var auditLoggerOnce sync.Once
+
+// audit writes entry as JSON line to the file configured as audit_log, or to the standard log prefixed with AUDIT
+// if no file is configured or it can not be opened.
+func audit(config configuration.Config, request *http.Request, entry auditEntry) {
+	auditLoggerOnce.Do(func() {
+		if config.AuditLog != "" {
+			file, err := os.OpenFile(config.AuditLog, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0640)
+			if err == nil {
+				auditLogger = log.New(file, "", 0)
+				return
+			}
+			log.Println("ERROR: unable to open audit log, using standard log", err)
+		}
+		auditLogger = log.New(log.Writer(), "AUDIT ", 0)
+	})
+	entry.Time = time.Now().UTC()
+	entry.User = request.Header.Get(userHeader)
+	entry.RemoteAddr = request.RemoteAddr
+	b, err := json.Marshal(entry)
+	if err != nil {
+		log.Println("ERROR: unable to write audit log", err)
+		return
+	}
+	auditLogger.Println(string(b))
+}
@@ -0,0 +1,211 @@
+/*
+ *    Copyright 2021 InfAI (CC SES)
+ *
+ *    Licensed under the Apache License, Version 2.0 (the "License");
+ *    you may not use this file except in compliance with the License.
+ *    You may obtain a copy of the License at
+ *
+ *        http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *    Unless required by applicable law or agreed to in writing, software
+ *    distributed under the License is distributed on an "AS IS" BASIS,
+ *    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *    See the License for the specific language governing permissions and
+ *    limitations under the License.
+ */
+
+package api
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/SENERGY-Platform/influx-wrapper/pkg/api/model"
+	"github.com/SENERGY-Platform/influx-wrapper/pkg/configuration"
+	influxdb "github.com/SENERGY-Platform/influx-wrapper/pkg/influx"
+	"github.com/SENERGY-Platform/influx-wrapper/pkg/metrics"
+	"github.com/SENERGY-Platform/influx-wrapper/pkg/util"
+	"github.com/orourkedd/influxdb1-client/models"
+	"net/http"
+	"time"
+)
+
+const csvContentType = "text/csv"
+
+// a table formatted response with its header
+type csvTable struct {
+	header []string
+	rows   [][]interface{}
+}
+
+// writes a table formatted response as csv. Rows are written one by one, so the csv document is never held in memory,
+// but the table itself is, since rows of multiple elements have to be merged and sorted. See csvStreamable for
+// requests which are streamed instead.
+func writeCSV(writer http.ResponseWriter, table csvTable) error {
+	writer.Header().Set("Content-Type", csvContentType)
+	csvWriter := csv.NewWriter(writer)
+	err := csvWriter.Write(table.header)
+	if err != nil {
+		return err
+	}
+	record := []string{}
+	for _, row := range table.rows {
+		record = record[:0]
+		for _, value := range row {
+			record = append(record, csvValue(value))
+		}
+		err = csvWriter.Write(record)
+		if err != nil {
+			return err
+		}
+	}
+	csvWriter.Flush()
+	return csvWriter.Error()
+}
+
+// csvStreamable reports if the csv response can be streamed from a chunked query without holding the result in memory.
+// This is the case for a single element ordered by time, without grouped tags, whose rows do not have to be merged or
+// joined. Elements with a limit are not streamed, since their continuation token has to be sent as header.
+func csvStreamable(queriesRequest queriesRequest) bool {
+	if len(queriesRequest.elements) != 1 || queriesRequest.orderColumnIndex != 0 || queriesRequest.join != model.JoinNone {
+		return false
+	}
+	element := queriesRequest.elements[0]
+	return len(element.GroupTags) == 0 && element.Limit == nil
+}
+
+// streamCSV writes the rows of a csvStreamable request chunk by chunk. Errors after the first chunk can not be reported
+// in csv, so the response ends early and the error is logged.
+func streamCSV(writer http.ResponseWriter, request *http.Request, config configuration.Config, influx *influxdb.Influx,
+	db string, queriesRequest queriesRequest) {
+	statements, err := influxdb.BuildQueries(queriesRequest.elements, queriesRequest.timeDirection())
+	if err == nil && len(statements) != 1 {
+		err = errors.New("unexpected number of statements")
+	}
+	var query string
+	if err == nil {
+		query, err = statements[0].Render()
+	}
+	if err != nil {
+		http.Error(writer, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	ctx, cancel := streamContext(config, request)
+	defer cancel()
+	controller, err := streamController(ctx, writer)
+	if err != nil {
+		http.Error(writer, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	csvWriter := csv.NewWriter(writer)
+	started := false
+	start := func() error {
+		if started {
+			return nil
+		}
+		started = true
+		writer.Header().Set("Content-Type", csvContentType)
+		return csvWriter.Write(csvHeader(queriesRequest.elements, []tableBlock{{element: 0}}))
+	}
+	rows := 0
+	record := []string{}
+	err = influx.StreamQuery(ctx, db, query, func(series models.Row) error {
+		if err := start(); err != nil {
+			return err
+		}
+		for _, row := range series.Values {
+			record = record[:0]
+			for i, value := range row {
+				if i == 0 {
+					str, ok := value.(string)
+					if !ok {
+						return errors.New("unexpected time value")
+					}
+					t, err := time.Parse(time.RFC3339, str)
+					if err != nil {
+						return err
+					}
+					value = t.In(queriesRequest.location)
+					if queriesRequest.timeFormat.isSet() {
+						value = queriesRequest.timeFormat.format(value.(time.Time))
+					}
+				}
+				record = append(record, csvValue(value))
+			}
+			if err := csvWriter.Write(record); err != nil {
+				return err
+			}
+		}
+		rows += len(series.Values)
+		csvWriter.Flush()
+		if err := csvWriter.Error(); err != nil {
+			return err
+		}
+		err := controller.Flush()
+		if err != nil && !errors.Is(err, http.ErrNotSupported) {
+			return err
+		}
+		return nil
+	})
+	if err != nil {
+		if !started {
+			writeInfluxError(writer, err)
+			return
+		}
+		fmt.Println("ERROR: " + err.Error())
+		return
+	}
+	err = start()
+	if err == nil {
+		csvWriter.Flush()
+		err = csvWriter.Error()
+	}
+	if err != nil {
+		fmt.Println("ERROR: " + err.Error())
+	}
+	metrics.ObserveResponseRows("/queries", rows)
+}
+
+// header names are <measurement>.<column>, including group type and math of the column. Grouped tags are named
+// <measurement>.<tag>.
+func csvHeader(request []model.QueriesRequestElement, blocks []tableBlock) []string {
+	header := []string{"time"}
+	for _, block := range blocks {
+		element := request[block.element]
+		for _, column := range element.Columns {
+			name := column.Name
+			if column.GroupType != nil {
+				name = *column.GroupType + "(" + name + ")"
+			}
+			if column.Math != nil {
+				name += *column.Math
+			}
+			header = append(header, element.Measurement+"."+name)
+		}
+		for _, tag := range element.GroupTags {
+			header = append(header, element.Measurement+"."+tag)
+		}
+	}
+	return header
+}
+
+func csvValue(value interface{}) string {
+	switch v := value.(type) {
+	case nil:
+		return ""
+	case time.Time:
+		return v.Format(time.RFC3339Nano)
+	case json.Number:
+		return v.String()
+	default:
+		str, err := util.String(v)
+		if err != nil {
+			b, _ := json.Marshal(v)
+			return string(b)
+		}
+		return str
+	}
+}
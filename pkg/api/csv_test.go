@@ -0,0 +1,163 @@
+/*
+ *    Copyright 2021 InfAI (CC SES)
+ *
+ *    Licensed under the Apache License, Version 2.0 (the "License");
+ *    you may not use this file except in compliance with the License.
+ *    You may obtain a copy of the License at
+ *
+ *        http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *    Unless required by applicable law or agreed to in writing, software
+ *    distributed under the License is distributed on an "AS IS" BASIS,
+ *    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *    See the License for the specific language governing permissions and
+ *    limitations under the License.
+ */
+
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"github.com/SENERGY-Platform/influx-wrapper/pkg/api/model"
+	"github.com/SENERGY-Platform/influx-wrapper/pkg/configuration"
+	influxdb "github.com/SENERGY-Platform/influx-wrapper/pkg/influx"
+	"github.com/SENERGY-Platform/influx-wrapper/pkg/tests/services"
+	"github.com/julienschmidt/httprouter"
+	influxLib "github.com/orourkedd/influxdb1-client"
+	"github.com/orourkedd/influxdb1-client/models"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCSVValue(t *testing.T) {
+	cases := []struct {
+		value  interface{}
+		expect string
+	}{
+		{nil, ""},
+		{true, "true"},
+		{false, "false"},
+		{json.Number("12.50"), "12.50"},
+		{json.Number("9007199254740993"), "9007199254740993"},
+		{1.5, "1.5"},
+		{"a,b", "a,b"},
+		{time.Date(2021, 1, 1, 1, 0, 0, 500, time.FixedZone("", 3600)), "2021-01-01T01:00:00.0000005+01:00"},
+	}
+	for _, c := range cases {
+		if actual := csvValue(c.value); actual != c.expect {
+			t.Error(c.value, "expected", c.expect, "actual", actual)
+		}
+	}
+}
+
+func TestCSVHeader(t *testing.T) {
+	mean := "mean"
+	math := "*2"
+	request := []model.QueriesRequestElement{
+		{Measurement: "m1", Columns: []model.QueriesRequestElementColumn{{Name: "c1", GroupType: &mean, Math: &math}}, GroupTags: []string{"device"}},
+		{Measurement: "m2", Columns: []model.QueriesRequestElementColumn{{Name: "c1"}, {Name: "c2"}}},
+	}
+	header := csvHeader(request, []tableBlock{{element: 0}, {element: 0}, {element: 1}})
+	expect := "time,m1.mean(c1)*2,m1.device,m1.mean(c1)*2,m1.device,m2.c1,m2.c2"
+	if strings.Join(header, ",") != expect {
+		t.Error(header)
+	}
+}
+
+func TestQueriesCSV(t *testing.T) {
+	influxClientMock := services.NewClientMock()
+	router := httprouter.New()
+	QueriesEndpoint(router, &configuration.ConfigStruct{}, influxdb.NewInfluxWithClient(&configuration.ConfigStruct{}, &influxClientMock))
+	rows := [][]interface{}{
+		{"2021-01-01T00:00:02Z", json.Number("2"), true, nil},
+		{"2021-01-01T00:00:01Z", json.Number("1.5"), false, "x"},
+	}
+	columns := []string{"time", "c1", "c2", "c3"}
+	body := `[{"measurement":"m1","columns":[{"name":"c1"},{"name":"c2"},{"name":"c3"}]}]`
+
+	query := func(t *testing.T, url string, accept string, body string) *httptest.ResponseRecorder {
+		request := httptest.NewRequest("POST", url, strings.NewReader(body))
+		request.Header.Set(userHeader, "db")
+		if accept != "" {
+			request.Header.Set("Accept", accept)
+		}
+		writer := httptest.NewRecorder()
+		router.ServeHTTP(writer, request)
+		return writer
+	}
+	expect := "time,m1.c1,m1.c2,m1.c3\n2021-01-01T00:00:02Z,2,true,\n2021-01-01T00:00:01Z,1.5,false,x\n"
+
+	t.Run("streamed", func(t *testing.T) {
+		influxClientMock.SetQueryHandler(func(q influxLib.Query) (*influxLib.Response, error) {
+			t.Error("unexpected query", q.Command)
+			return nil, nil
+		})
+		defer influxClientMock.SetQueryHandler(nil)
+		influxClientMock.SetChunkedResponse([]*influxLib.Response{
+			{Results: []influxLib.Result{{Series: []models.Row{{Name: "m1", Columns: columns, Values: rows[:1]}}}}},
+			{Results: []influxLib.Result{{Series: []models.Row{{Name: "m1", Columns: columns, Values: rows[1:]}}}}},
+		}, nil)
+		writer := query(t, "/queries", "text/csv", body)
+		if writer.Code != 200 || writer.Header().Get("Content-Type") != csvContentType {
+			t.Error(writer.Code, writer.Header())
+		}
+		if writer.Body.String() != expect {
+			t.Error("\nexpect\n", expect, "\nactual\n", writer.Body.String())
+		}
+	})
+	t.Run("streamed without rows", func(t *testing.T) {
+		influxClientMock.SetChunkedResponse([]*influxLib.Response{{Results: []influxLib.Result{{}}}}, nil)
+		writer := query(t, "/queries?format=csv", "", body)
+		if writer.Header().Get("Content-Type") != csvContentType || writer.Body.String() != "time,m1.c1,m1.c2,m1.c3\n" {
+			t.Error(writer.Header(), writer.Body.String())
+		}
+	})
+	t.Run("merged", func(t *testing.T) {
+		influxClientMock.SetQueryResponse(&influxLib.Response{Results: []influxLib.Result{
+			{Series: []models.Row{{Name: "m1", Columns: columns, Values: [][]interface{}{
+				{"2021-01-01T00:00:02Z", json.Number("2"), true, nil},
+				{"2021-01-01T00:00:01Z", json.Number("1.5"), false, "x"},
+			}}}},
+			{Series: []models.Row{{Name: "m2", Columns: []string{"time", "c1"}, Values: [][]interface{}{{"2021-01-01T00:00:01Z", json.Number("7")}}}}},
+		}}, nil)
+		writer := query(t, "/queries?timezone=Europe/Berlin", "text/csv", `[{"measurement":"m1","columns":[{"name":"c1"},{"name":"c2"},{"name":"c3"}]},{"measurement":"m2","columns":[{"name":"c1"}]}]`)
+		if writer.Code != 200 || writer.Header().Get("Content-Type") != csvContentType {
+			t.Error(writer.Code, writer.Header())
+		}
+		expect := "time,m1.c1,m1.c2,m1.c3,m2.c1\n2021-01-01T01:00:02+01:00,2,true,,\n2021-01-01T01:00:01+01:00,1.5,false,x,7\n"
+		if writer.Body.String() != expect {
+			t.Error("\nexpect\n", expect, "\nactual\n", writer.Body.String())
+		}
+	})
+	t.Run("joined", func(t *testing.T) {
+		influxClientMock.SetQueryResponse(&influxLib.Response{Results: []influxLib.Result{
+			{Series: []models.Row{{Name: "m1", Columns: []string{"time", "c1", "c2"}, Values: [][]interface{}{
+				{"2021-01-01T00:00:03Z", json.Number("3"), nil},
+				{"2021-01-01T00:00:02Z", nil, json.Number("20")},
+				{"2021-01-01T00:00:01Z", json.Number("1"), json.Number("10")},
+			}}}},
+		}}, nil)
+		influxClientMock.SetChunkedResponse(nil, errors.New("unexpected chunked query"))
+		defer influxClientMock.SetChunkedResponse(nil, nil)
+		writer := query(t, "/queries?format=csv&join=forward_fill", "", `[{"measurement":"m1","columns":[{"name":"c1"},{"name":"c2"}]}]`)
+		if writer.Code != 200 || writer.Header().Get("Content-Type") != csvContentType {
+			t.Error(writer.Code, writer.Header(), writer.Body.String())
+		}
+		expect := "time,m1.c1,m1.c2\n2021-01-01T00:00:03Z,3,20\n2021-01-01T00:00:02Z,1,20\n2021-01-01T00:00:01Z,1,10\n"
+		if writer.Body.String() != expect {
+			t.Error("\nexpect\n", expect, "\nactual\n", writer.Body.String())
+		}
+	})
+	t.Run("json by default", func(t *testing.T) {
+		influxClientMock.SetQueryResponse(&influxLib.Response{Results: []influxLib.Result{
+			{Series: []models.Row{{Name: "m1", Columns: columns, Values: [][]interface{}{{"2021-01-01T00:00:02Z", json.Number("2"), true, nil}}}}},
+		}}, nil)
+		writer := query(t, "/queries", "application/json", body)
+		if writer.Code != 200 || writer.Header().Get("Content-Type") != "application/json" {
+			t.Error(writer.Code, writer.Header(), writer.Body.String())
+		}
+	})
+}
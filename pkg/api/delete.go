@@ -0,0 +1,102 @@
+/*
+ *    Copyright 2021 InfAI (CC SES)
+ *
+ *    Licensed under the Apache License, Version 2.0 (the "License");
+ *    you may not use this file except in compliance with the License.
+ *    You may obtain a copy of the License at
+ *
+ *        http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *    Unless required by applicable law or agreed to in writing, software
+ *    distributed under the License is distributed on an "AS IS" BASIS,
+ *    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *    See the License for the specific language governing permissions and
+ *    limitations under the License.
+ */
+
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/SENERGY-Platform/influx-wrapper/pkg/api/model"
+	"github.com/SENERGY-Platform/influx-wrapper/pkg/configuration"
+	influxdb "github.com/SENERGY-Platform/influx-wrapper/pkg/influx"
+	"github.com/julienschmidt/httprouter"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+func init() {
+	endpoints = append(endpoints, DeleteEndpoint)
+}
+
+// DeleteEndpoint deletes points of a measurement selected by a model.DeleteRequest. With the query param dry_run the
+// points are only counted. Deletions are written to the audit log.
+func DeleteEndpoint(router *httprouter.Router, config configuration.Config, influx *influxdb.Influx) {
+	router.DELETE("/measurements/:id", func(writer http.ResponseWriter, request *http.Request, params httprouter.Params) {
+		start := time.Now()
+		id := params.ByName("id")
+		db := request.Header.Get(userHeader)
+		if db == "" {
+			http.Error(writer, "Missing header "+userHeader, http.StatusBadRequest)
+			return
+		}
+		dryRun := false
+		if request.URL.Query().Has("dry_run") {
+			var err error
+			dryRun, err = strconv.ParseBool(request.URL.Query().Get("dry_run"))
+			if err != nil {
+				http.Error(writer, "Invalid param dry_run", http.StatusBadRequest)
+				return
+			}
+		}
+
+		deleteRequest := model.DeleteRequest{}
+		err := json.NewDecoder(request.Body).Decode(&deleteRequest)
+		if err != nil && err != io.EOF {
+			http.Error(writer, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if validationErrors := deleteRequest.Validate(); len(validationErrors) > 0 {
+			writeBadRequest(writer, validationErrors)
+			return
+		}
+
+		ctx, cancel := queryContext(config, request)
+		defer cancel()
+		if dryRun {
+			count, err := influx.CountPoints(ctx, db, id, deleteRequest)
+			if err != nil {
+				writeInfluxError(writer, err)
+				return
+			}
+			writer.Header().Set("Content-Type", "application/json")
+			err = json.NewEncoder(writer).Encode(model.DeleteResponse{DryRun: true, Points: count})
+			if err != nil {
+				fmt.Println("ERROR: " + err.Error())
+			}
+		} else {
+			statement, err := influx.DeletePoints(ctx, db, id, deleteRequest)
+			entry := auditEntry{Action: "delete", Measurement: id, Statement: statement}
+			if err != nil {
+				entry.Error = err.Error()
+			}
+			if statement != "" {
+				audit(config, request, entry)
+			}
+			if err != nil {
+				writeInfluxError(writer, err)
+				return
+			}
+			writer.WriteHeader(http.StatusNoContent)
+		}
+
+		if config.Debug {
+			log.Println("Took " + time.Since(start).String())
+		}
+	})
+}
@@ -0,0 +1,48 @@
+/*
+ *    Copyright 2021 InfAI (CC SES)
+ *
+ *    Licensed under the Apache License, Version 2.0 (the "License");
+ *    you may not use this file except in compliance with the License.
+ *    You may obtain a copy of the License at
+ *
+ *        http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *    Unless required by applicable law or agreed to in writing, software
+ *    distributed under the License is distributed on an "AS IS" BASIS,
+ *    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *    See the License for the specific language governing permissions and
+ *    limitations under the License.
+ */
+
+package api
+
+import (
+	"context"
+	influxdb "github.com/SENERGY-Platform/influx-wrapper/pkg/influx"
+	"net/http"
+)
+
+// StatusClientClosedRequest is used if the client disconnected before a response could be sent
+const StatusClientClosedRequest = 499
+
+// writes errors returned from influxdb.Influx with a matching status code
+func writeInfluxError(writer http.ResponseWriter, err error) {
+	switch err {
+	case influxdb.ErrInfluxConnection, influxdb.ErrNULL:
+		http.Error(writer, err.Error(), http.StatusBadGateway)
+	case influxdb.ErrNotFound:
+		http.Error(writer, err.Error(), http.StatusNotFound)
+	case influxdb.ErrInvalidMath:
+		http.Error(writer, err.Error(), http.StatusBadRequest)
+	case influxdb.ErrRetentionPolicyExists, influxdb.ErrRetentionPolicyLimit:
+		http.Error(writer, err.Error(), http.StatusConflict)
+	case influxdb.ErrRetentionPolicyProtected:
+		http.Error(writer, err.Error(), http.StatusForbidden)
+	case influxdb.ErrTimeout:
+		http.Error(writer, err.Error(), http.StatusGatewayTimeout)
+	case context.Canceled:
+		http.Error(writer, err.Error(), StatusClientClosedRequest)
+	default:
+		http.Error(writer, err.Error(), http.StatusInternalServerError)
+	}
+}
@@ -0,0 +1,77 @@
+/*
+ *    Copyright 2021 InfAI (CC SES)
+ *
+ *    Licensed under the Apache License, Version 2.0 (the "License");
+ *    you may not use this file except in compliance with the License.
+ *    You may obtain a copy of the License at
+ *
+ *        http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *    Unless required by applicable law or agreed to in writing, software
+ *    distributed under the License is distributed on an "AS IS" BASIS,
+ *    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *    See the License for the specific language governing permissions and
+ *    limitations under the License.
+ */
+
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/SENERGY-Platform/influx-wrapper/pkg/api/model"
+	"github.com/SENERGY-Platform/influx-wrapper/pkg/configuration"
+	influxdb "github.com/SENERGY-Platform/influx-wrapper/pkg/influx"
+	"github.com/julienschmidt/httprouter"
+	"net/http"
+)
+
+func init() {
+	endpoints = append(endpoints, QueriesExplainEndpoint)
+}
+
+// QueriesExplainEndpoint answers with the statements a /queries request would execute, without contacting influx
+func QueriesExplainEndpoint(router *httprouter.Router, config configuration.Config, influx *influxdb.Influx) {
+	router.POST("/queries/explain", func(writer http.ResponseWriter, request *http.Request, params httprouter.Params) {
+		queriesRequest, err := parseQueriesRequest(request)
+		if err != nil {
+			writeBadRequest(writer, err)
+			return
+		}
+
+		timeDirection := queriesRequest.timeDirection()
+		statements, err := influxdb.BuildQueries(queriesRequest.elements, timeDirection)
+		if err != nil {
+			http.Error(writer, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		response := model.QueriesExplainResponse{
+			Format:           queriesRequest.format,
+			OrderColumnIndex: queriesRequest.orderColumnIndex,
+			OrderDirection:   queriesRequest.orderDirection,
+			TimeFormat:       queriesRequest.timeFormat.layout,
+			Epoch:            queriesRequest.timeFormat.epochName,
+			Elements:         []model.QueriesExplainElement{},
+		}
+		for i := range statements {
+			query, err := statements[i].Render()
+			if err != nil {
+				http.Error(writer, err.Error(), http.StatusInternalServerError)
+				return
+			}
+			response.Elements = append(response.Elements, model.QueriesExplainElement{
+				Query:            query,
+				TimeDirection:    timeDirection,
+				OrderColumnIndex: *queriesRequest.elements[i].OrderColumnIndex,
+				OrderDirection:   *queriesRequest.elements[i].OrderDirection,
+			})
+		}
+
+		writer.Header().Set("Content-Type", "application/json")
+		err = json.NewEncoder(writer).Encode(response)
+		if err != nil {
+			fmt.Println("ERROR: " + err.Error())
+		}
+	})
+}
@@ -0,0 +1,120 @@
+/*
+ *    Copyright 2021 InfAI (CC SES)
+ *
+ *    Licensed under the Apache License, Version 2.0 (the "License");
+ *    you may not use this file except in compliance with the License.
+ *    You may obtain a copy of the License at
+ *
+ *        http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *    Unless required by applicable law or agreed to in writing, software
+ *    distributed under the License is distributed on an "AS IS" BASIS,
+ *    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *    See the License for the specific language governing permissions and
+ *    limitations under the License.
+ */
+
+package api
+
+import (
+	"encoding/json"
+	"github.com/SENERGY-Platform/influx-wrapper/pkg/api/model"
+	"github.com/SENERGY-Platform/influx-wrapper/pkg/configuration"
+	influxdb "github.com/SENERGY-Platform/influx-wrapper/pkg/influx"
+	"github.com/SENERGY-Platform/influx-wrapper/pkg/tests/services"
+	"github.com/julienschmidt/httprouter"
+	influxLib "github.com/orourkedd/influxdb1-client"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestQueriesExplain(t *testing.T) {
+	influxClientMock := services.NewClientMock()
+	influxClientMock.SetQueryHandler(func(q influxLib.Query) (*influxLib.Response, error) {
+		t.Error("unexpected query", q.Command)
+		return nil, nil
+	})
+	config := &configuration.ConfigStruct{}
+	router := httprouter.New()
+	QueriesExplainEndpoint(router, config, influxdb.NewInfluxWithClient(config, &influxClientMock))
+
+	explain := func(t *testing.T, url string, body string) (code int, response model.QueriesExplainResponse) {
+		request := httptest.NewRequest("POST", url, strings.NewReader(body))
+		request.Header.Set(userHeader, "db")
+		writer := httptest.NewRecorder()
+		router.ServeHTTP(writer, request)
+		if writer.Code == 200 {
+			err := json.NewDecoder(writer.Body).Decode(&response)
+			if err != nil {
+				t.Error(err)
+			}
+		}
+		return writer.Code, response
+	}
+
+	t.Run("defaults", func(t *testing.T) {
+		code, response := explain(t, "/queries/explain",
+			`[{"measurement":"m1","columns":[{"name":"c1"}],"limit":5},{"measurement":"m2","columns":[{"name":"c1"},{"name":"c2"}]}]`)
+		if code != 200 {
+			t.Fatal(code)
+		}
+		expect := model.QueriesExplainResponse{
+			Format:           model.PerQuery,
+			OrderColumnIndex: 0,
+			OrderDirection:   model.Desc,
+			TimeFormat:       "",
+			Elements: []model.QueriesExplainElement{
+				{Query: "SELECT \"c1\" FROM \"m1\" ORDER BY time DESC LIMIT 5", TimeDirection: model.Desc, OrderColumnIndex: 0, OrderDirection: model.Desc},
+				{Query: "SELECT \"c1\", \"c2\" FROM \"m2\" ORDER BY time DESC", TimeDirection: model.Desc, OrderColumnIndex: 0, OrderDirection: model.Desc},
+			},
+		}
+		if !reflect.DeepEqual(response, expect) {
+			t.Errorf("\n%#v\n%#v", response, expect)
+		}
+	})
+	t.Run("resolved element ordering", func(t *testing.T) {
+		code, response := explain(t, "/queries/explain?order_direction=asc&time_format=rfc3339",
+			`[{"measurement":"m1","columns":[{"name":"c1"}],"orderColumnIndex":1,"orderDirection":"desc"},{"measurement":"m2","columns":[{"name":"c1"}]}]`)
+		if code != 200 {
+			t.Fatal(code)
+		}
+		if response.Format != model.PerQuery || response.OrderDirection != model.Asc || response.TimeFormat != time.RFC3339 || len(response.Elements) != 2 {
+			t.Fatalf("%#v", response)
+		}
+		// the request is sorted by time ascending, so influx is queried ascending
+		for i, element := range response.Elements {
+			if element.TimeDirection != model.Asc || !strings.HasSuffix(element.Query, "ORDER BY time ASC") {
+				t.Errorf("%d: %#v", i, element)
+			}
+		}
+		if response.Elements[0].OrderColumnIndex != 1 || response.Elements[0].OrderDirection != model.Desc {
+			t.Errorf("%#v", response.Elements[0])
+		}
+		if response.Elements[1].OrderColumnIndex != 0 || response.Elements[1].OrderDirection != model.Desc {
+			t.Errorf("%#v", response.Elements[1])
+		}
+	})
+	t.Run("resolved format", func(t *testing.T) {
+		code, response := explain(t, "/queries/explain?format=table&order_column_index=1&order_direction=asc&epoch=ms",
+			`[{"measurement":"m1","columns":[{"name":"c1"}]}]`)
+		if code != 200 {
+			t.Fatal(code)
+		}
+		if response.Format != model.Table || response.OrderColumnIndex != 1 || response.OrderDirection != model.Asc || response.Epoch != "ms" || len(response.Elements) != 1 {
+			t.Fatalf("%#v", response)
+		}
+		// sorted by a value column, influx is queried by time descending
+		if response.Elements[0].TimeDirection != model.Desc || response.Elements[0].Query != "SELECT \"c1\" FROM \"m1\" ORDER BY time DESC" {
+			t.Errorf("%#v", response.Elements[0])
+		}
+	})
+	t.Run("invalid", func(t *testing.T) {
+		code, _ := explain(t, "/queries/explain", `[{"measurement":"m1"}]`)
+		if code != 400 {
+			t.Error(code)
+		}
+	})
+}
@@ -0,0 +1,110 @@
+/*
+ *    Copyright 2021 InfAI (CC SES)
+ *
+ *    Licensed under the Apache License, Version 2.0 (the "License");
+ *    you may not use this file except in compliance with the License.
+ *    You may obtain a copy of the License at
+ *
+ *        http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *    Unless required by applicable law or agreed to in writing, software
+ *    distributed under the License is distributed on an "AS IS" BASIS,
+ *    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *    See the License for the specific language governing permissions and
+ *    limitations under the License.
+ */
+
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/SENERGY-Platform/influx-wrapper/pkg/api/model"
+	"github.com/SENERGY-Platform/influx-wrapper/pkg/configuration"
+	influxdb "github.com/SENERGY-Platform/influx-wrapper/pkg/influx"
+	"github.com/julienschmidt/httprouter"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+func init() {
+	endpoints = append(endpoints, HealthEndpoint)
+}
+
+// set to 1 as soon as the server starts shutting down
+var draining int32
+
+const pingTimeout = 2 * time.Second
+
+func HealthEndpoint(router *httprouter.Router, config configuration.Config, influx *influxdb.Influx) {
+	cacheDuration, err := time.ParseDuration(config.ReadyCache)
+	if err != nil {
+		cacheDuration = 0
+	}
+	var mux sync.Mutex
+	var cached model.ReadinessResponse
+	// closed as soon as the running check finished, nil if no check is running
+	var refreshing chan struct{}
+
+	router.GET("/health", func(writer http.ResponseWriter, request *http.Request, params httprouter.Params) {
+		writer.WriteHeader(http.StatusOK)
+	})
+
+	router.GET("/ready", func(writer http.ResponseWriter, request *http.Request, params httprouter.Params) {
+		mux.Lock()
+		if time.Since(cached.CheckedAt) >= cacheDuration {
+			// concurrent requests wait for the same check, the mutex is not held while pinging
+			done := refreshing
+			if done == nil {
+				done = make(chan struct{})
+				refreshing = done
+				go func() {
+					result := checkReadiness(influx)
+					mux.Lock()
+					cached = result
+					refreshing = nil
+					mux.Unlock()
+					close(done)
+				}()
+			}
+			mux.Unlock()
+			<-done
+			mux.Lock()
+		}
+		response := cached
+		mux.Unlock()
+
+		response.Draining = atomic.LoadInt32(&draining) == 1
+		response.Ready = response.InfluxReachable && !response.Draining
+
+		writer.Header().Set("Content-Type", "application/json")
+		if response.Ready {
+			writer.WriteHeader(http.StatusOK)
+		} else {
+			writer.WriteHeader(http.StatusServiceUnavailable)
+		}
+		err := json.NewEncoder(writer).Encode(response)
+		if err != nil {
+			fmt.Println("ERROR: " + err.Error())
+		}
+	})
+}
+
+// results are shared between requests, so the ping is not bound to the context of a single request
+func checkReadiness(influx *influxdb.Influx) (result model.ReadinessResponse) {
+	ctx, cancel := context.WithTimeout(context.Background(), pingTimeout)
+	defer cancel()
+	latency, version, err := influx.Ping(ctx)
+	result.CheckedAt = time.Now()
+	if err != nil {
+		result.Error = err.Error()
+		return result
+	}
+	result.InfluxReachable = true
+	result.InfluxVersion = version
+	result.InfluxLatencyMs = float64(latency) / float64(time.Millisecond)
+	return result
+}
@@ -0,0 +1,123 @@
+/*
+ *    Copyright 2021 InfAI (CC SES)
+ *
+ *    Licensed under the Apache License, Version 2.0 (the "License");
+ *    you may not use this file except in compliance with the License.
+ *    You may obtain a copy of the License at
+ *
+ *        http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *    Unless required by applicable law or agreed to in writing, software
+ *    distributed under the License is distributed on an "AS IS" BASIS,
+ *    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *    See the License for the specific language governing permissions and
+ *    limitations under the License.
+ */
+
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/SENERGY-Platform/influx-wrapper/pkg/api/model"
+	"github.com/SENERGY-Platform/influx-wrapper/pkg/configuration"
+	influxdb "github.com/SENERGY-Platform/influx-wrapper/pkg/influx"
+	"github.com/SENERGY-Platform/influx-wrapper/pkg/tests/services"
+	"github.com/julienschmidt/httprouter"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestReadyEndpoint(t *testing.T) {
+	influxClientMock := services.NewClientMock()
+	var pings int32
+	release := make(chan struct{})
+	influxClientMock.SetPingHandler(func(ctx context.Context) (time.Duration, string, error) {
+		atomic.AddInt32(&pings, 1)
+		<-release
+		return time.Millisecond, "1.8.10", nil
+	})
+	config := &configuration.ConfigStruct{ReadyCache: "1m"}
+	router := httprouter.New()
+	HealthEndpoint(router, config, influxdb.NewInfluxWithClient(config, &influxClientMock))
+
+	ready := func() int {
+		writer := httptest.NewRecorder()
+		router.ServeHTTP(writer, httptest.NewRequest("GET", "/ready", nil))
+		return writer.Code
+	}
+
+	t.Run("concurrent requests share one ping", func(t *testing.T) {
+		wg := sync.WaitGroup{}
+		codes := make([]int, 3)
+		for i := range codes {
+			wg.Add(1)
+			go func(i int) {
+				defer wg.Done()
+				codes[i] = ready()
+			}(i)
+		}
+		time.Sleep(50 * time.Millisecond)
+		close(release)
+		wg.Wait()
+		for i, code := range codes {
+			if code != 200 {
+				t.Error(i, code)
+			}
+		}
+		if atomic.LoadInt32(&pings) != 1 {
+			t.Error("expected 1 ping, got", atomic.LoadInt32(&pings))
+		}
+	})
+	t.Run("cached", func(t *testing.T) {
+		if code := ready(); code != 200 {
+			t.Error(code)
+		}
+		if atomic.LoadInt32(&pings) != 1 {
+			t.Error("expected 1 ping, got", atomic.LoadInt32(&pings))
+		}
+	})
+}
+
+func TestReadyEndpointPingStatus(t *testing.T) {
+	for status, ready := range map[int]bool{http.StatusNoContent: true, http.StatusBadGateway: false, http.StatusUnauthorized: false} {
+		influxServer := httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+			if request.URL.Path != "/ping" {
+				t.Error("unexpected request", request.URL.Path)
+			}
+			writer.Header().Set("X-Influxdb-Version", "1.8.10")
+			writer.WriteHeader(status)
+		}))
+		config := &configuration.ConfigStruct{InfluxDbUrl: influxServer.URL}
+		influx, err := influxdb.NewInflux(config)
+		if err != nil {
+			t.Fatal(err)
+		}
+		router := httprouter.New()
+		HealthEndpoint(router, config, influx)
+		writer := httptest.NewRecorder()
+		router.ServeHTTP(writer, httptest.NewRequest("GET", "/ready", nil))
+		influxServer.Close()
+
+		response := model.ReadinessResponse{}
+		err = json.NewDecoder(writer.Body).Decode(&response)
+		if err != nil {
+			t.Error(status, err)
+			continue
+		}
+		expectCode := http.StatusOK
+		if !ready {
+			expectCode = http.StatusServiceUnavailable
+		}
+		if writer.Code != expectCode || response.Ready != ready || response.InfluxReachable != ready {
+			t.Error(status, writer.Code, response)
+		}
+		if !ready && response.Error != influxdb.ErrInfluxConnection.Error() {
+			t.Error(status, response.Error)
+		}
+	}
+}
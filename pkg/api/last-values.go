@@ -19,8 +19,10 @@ package api
 import (
 	"encoding/json"
 	"fmt"
+	"github.com/SENERGY-Platform/influx-wrapper/pkg/api/model"
 	"github.com/SENERGY-Platform/influx-wrapper/pkg/configuration"
 	influxdb "github.com/SENERGY-Platform/influx-wrapper/pkg/influx"
+	"github.com/SENERGY-Platform/influx-wrapper/pkg/metrics"
 	"github.com/julienschmidt/httprouter"
 	"log"
 	"net/http"
@@ -43,12 +45,31 @@ func LastValuesEndpoint(router *httprouter.Router, config configuration.Config,
 			return
 		}
 
+		timeFormat, err := parseTimeFormat(request.URL.Query())
+		if err != nil {
+			http.Error(writer, err.Error(), http.StatusBadRequest)
+			return
+		}
+		location, err := time.LoadLocation(request.URL.Query().Get("timezone"))
+		if err != nil || request.URL.Query().Get("timezone") == "Local" {
+			http.Error(writer, "Invalid param timezone", http.StatusBadRequest)
+			return
+		}
+
 		var requestElements []influxdb.RequestElement
-		err := json.NewDecoder(request.Body).Decode(&requestElements)
+		err = json.NewDecoder(request.Body).Decode(&requestElements)
 		if err != nil {
 			http.Error(writer, err.Error(), http.StatusBadRequest)
 			return
 		}
+		var validationErrors model.ValidationErrors
+		for i := range requestElements {
+			validationErrors = append(validationErrors, requestElements[i].Validate(i)...)
+		}
+		if len(validationErrors) > 0 {
+			writeBadRequest(writer, validationErrors)
+			return
+		}
 
 		if config.Debug {
 			log.Print("Request:")
@@ -58,24 +79,48 @@ func LastValuesEndpoint(router *httprouter.Router, config configuration.Config,
 			}
 		}
 
-		responseElements, err := influx.GetLatestValues(db, requestElements)
-
+		ctx, cancel := queryContext(config, request)
+		defer cancel()
+		responseElements, elementErrors, err := influx.GetLastValues(ctx, db, requestElements)
 		if err != nil {
-			switch err {
-			case influxdb.ErrInfluxConnection, influxdb.ErrNULL:
-				http.Error(writer, err.Error(), http.StatusBadGateway)
-				return
-			case influxdb.ErrNotFound:
-				http.Error(writer, err.Error(), http.StatusNotFound)
-				return
-			default:
-				http.Error(writer, err.Error(), http.StatusInternalServerError)
-				return
+			writeInfluxError(writer, err)
+			return
+		}
+
+		// elements that can not return multiple values keep the single value shape
+		response := make([]interface{}, len(responseElements))
+		rows := 0
+		for i, values := range responseElements {
+			if elementErrors[i] != nil {
+				// failed elements always use the single value shape, other elements are returned as usual
+				response[i] = model.TimeValuePair{Error: elementErrors[i].Error()}
+				continue
+			}
+			pairs := make([]model.TimeValuePair, len(values))
+			for j, value := range values {
+				pairs[j].Value = value.Value
+				pairs[j].Tags = value.Tags
+				if value.Time != nil {
+					pairs[j].Time, err = timeFormat.formatString(*value.Time, location)
+					if err != nil {
+						http.Error(writer, err.Error(), http.StatusInternalServerError)
+						return
+					}
+				}
+			}
+			rows += len(pairs)
+			if requestElements[i].Multiple() {
+				response[i] = pairs
+			} else if len(pairs) > 0 {
+				response[i] = pairs[0]
+			} else {
+				response[i] = model.TimeValuePair{}
 			}
 		}
 
+		metrics.ObserveResponseRows("/last-values", rows)
 		writer.Header().Set("Content-Type", "application/json")
-		err = json.NewEncoder(writer).Encode(responseElements)
+		err = json.NewEncoder(writer).Encode(response)
 		if err != nil {
 			fmt.Println("ERROR: " + err.Error())
 		}
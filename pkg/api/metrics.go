@@ -0,0 +1,72 @@
+/*
+ *    Copyright 2021 InfAI (CC SES)
+ *
+ *    Licensed under the Apache License, Version 2.0 (the "License");
+ *    you may not use this file except in compliance with the License.
+ *    You may obtain a copy of the License at
+ *
+ *        http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *    Unless required by applicable law or agreed to in writing, software
+ *    distributed under the License is distributed on an "AS IS" BASIS,
+ *    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *    See the License for the specific language governing permissions and
+ *    limitations under the License.
+ */
+
+package api
+
+import (
+	"github.com/SENERGY-Platform/influx-wrapper/pkg/configuration"
+	influxdb "github.com/SENERGY-Platform/influx-wrapper/pkg/influx"
+	"github.com/julienschmidt/httprouter"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"net/http"
+	"strings"
+)
+
+func init() {
+	endpoints = append(endpoints, MetricsEndpoint)
+}
+
+func MetricsEndpoint(router *httprouter.Router, config configuration.Config, influx *influxdb.Influx) {
+	router.Handler(http.MethodGet, "/metrics", promhttp.Handler())
+}
+
+const unmatchedRoute = "unmatched"
+
+// replaces single path segments to find out which segments are parameters
+const routeProbe = "_route_probe_"
+
+// returns a function resolving requests to the route pattern they match, e.g. /tags/:id
+func routeResolver(router *httprouter.Router) func(request *http.Request) string {
+	return func(request *http.Request) string {
+		handle, params, _ := router.Lookup(request.Method, request.URL.Path)
+		if handle == nil {
+			return unmatchedRoute
+		}
+		if len(params) == 0 {
+			return request.URL.Path
+		}
+		// a segment is a parameter if replacing it changes the value of a parameter, values equal to static
+		// segments of the route are not mistaken for parameters
+		segments := strings.Split(request.URL.Path, "/")
+		route := make([]string, len(segments))
+		for i := range segments {
+			route[i] = segments[i]
+			if segments[i] == "" {
+				continue
+			}
+			probe := append([]string{}, segments...)
+			probe[i] = routeProbe
+			_, probeParams, _ := router.Lookup(request.Method, strings.Join(probe, "/"))
+			for _, param := range probeParams {
+				if param.Value == routeProbe {
+					route[i] = ":" + param.Key
+					break
+				}
+			}
+		}
+		return strings.Join(route, "/")
+	}
+}
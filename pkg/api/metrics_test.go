@@ -0,0 +1,57 @@
+/*
+ *    Copyright 2021 InfAI (CC SES)
+ *
+ *    Licensed under the Apache License, Version 2.0 (the "License");
+ *    you may not use this file except in compliance with the License.
+ *    You may obtain a copy of the License at
+ *
+ *        http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *    Unless required by applicable law or agreed to in writing, software
+ *    distributed under the License is distributed on an "AS IS" BASIS,
+ *    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *    See the License for the specific language governing permissions and
+ *    limitations under the License.
+ */
+
+package api
+
+import (
+	"github.com/julienschmidt/httprouter"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestRouteResolver(t *testing.T) {
+	router := httprouter.New()
+	handle := func(writer http.ResponseWriter, request *http.Request, params httprouter.Params) {}
+	router.GET("/measurements", handle)
+	router.GET("/measurements/:id/field-keys", handle)
+	router.DELETE("/measurements/:id", handle)
+	router.GET("/tags/:id", handle)
+	resolve := routeResolver(router)
+
+	cases := []struct {
+		method string
+		path   string
+		expect string
+	}{
+		{"GET", "/measurements", "/measurements"},
+		{"GET", "/measurements/abc/field-keys", "/measurements/:id/field-keys"},
+		{"GET", "/measurements/measurements/field-keys", "/measurements/:id/field-keys"},
+		{"GET", "/measurements/field-keys/field-keys", "/measurements/:id/field-keys"},
+		{"DELETE", "/measurements/abc", "/measurements/:id"},
+		{"GET", "/tags/tags", "/tags/:id"},
+		{"GET", "/unknown", unmatchedRoute},
+		{"GET", "/unknown/abc/def", unmatchedRoute},
+		{"POST", "/measurements", unmatchedRoute},
+		{"GET", "/measurements/abc/tag-values", unmatchedRoute},
+	}
+	for _, c := range cases {
+		actual := resolve(httptest.NewRequest(c.method, c.path, nil))
+		if actual != c.expect {
+			t.Errorf("%s %s: expected %s, got %s", c.method, c.path, c.expect, actual)
+		}
+	}
+}
@@ -0,0 +1,52 @@
+/*
+ *    Copyright 2021 InfAI (CC SES)
+ *
+ *    Licensed under the Apache License, Version 2.0 (the "License");
+ *    you may not use this file except in compliance with the License.
+ *    You may obtain a copy of the License at
+ *
+ *        http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *    Unless required by applicable law or agreed to in writing, software
+ *    distributed under the License is distributed on an "AS IS" BASIS,
+ *    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *    See the License for the specific language governing permissions and
+ *    limitations under the License.
+ */
+
+package model
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"time"
+)
+
+// Cursor continues a raw query after the last returned timestamp, in the time direction of the original query. Rows of
+// different series may share a timestamp, so Skip rows with exactly this timestamp were already returned. Tokens
+// without Skip continue strictly after Time. Clients only see the opaque token.
+type Cursor struct {
+	Time      time.Time `json:"t"`
+	Direction Direction `json:"d"`
+	Skip      int       `json:"s,omitempty"`
+}
+
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+func (cursor Cursor) Token() string {
+	b, _ := json.Marshal(cursor)
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+func ParseCursor(token string) (cursor Cursor, err error) {
+	b, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil {
+		return cursor, ErrInvalidCursor
+	}
+	err = json.Unmarshal(b, &cursor)
+	if err != nil || cursor.Time.IsZero() || cursor.Skip < 0 || (cursor.Direction != Asc && cursor.Direction != Desc) {
+		return cursor, ErrInvalidCursor
+	}
+	return cursor, nil
+}
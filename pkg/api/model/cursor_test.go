@@ -0,0 +1,45 @@
+/*
+ *    Copyright 2021 InfAI (CC SES)
+ *
+ *    Licensed under the Apache License, Version 2.0 (the "License");
+ *    you may not use this file except in compliance with the License.
+ *    You may obtain a copy of the License at
+ *
+ *        http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *    Unless required by applicable law or agreed to in writing, software
+ *    distributed under the License is distributed on an "AS IS" BASIS,
+ *    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *    See the License for the specific language governing permissions and
+ *    limitations under the License.
+ */
+
+package model
+
+import (
+	"testing"
+	"time"
+)
+
+func TestCursor(t *testing.T) {
+	t.Run("round trip", func(t *testing.T) {
+		cursor := Cursor{Time: time.Date(2021, 1, 1, 0, 0, 0, 123456789, time.UTC), Direction: Asc, Skip: 3}
+		parsed, err := ParseCursor(cursor.Token())
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		if !parsed.Time.Equal(cursor.Time) || parsed.Direction != cursor.Direction || parsed.Skip != cursor.Skip {
+			t.Error("expected", cursor, "actual", parsed)
+		}
+	})
+
+	t.Run("invalid", func(t *testing.T) {
+		for _, token := range []string{"", "not base64!", "e30", Cursor{Time: time.Now(), Direction: "up"}.Token(), Cursor{Time: time.Now(), Direction: Asc, Skip: -1}.Token()} {
+			_, err := ParseCursor(token)
+			if err != ErrInvalidCursor {
+				t.Error(token, "expected ErrInvalidCursor, got", err)
+			}
+		}
+	})
+}
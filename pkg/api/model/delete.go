@@ -0,0 +1,49 @@
+/*
+ *    Copyright 2021 InfAI (CC SES)
+ *
+ *    Licensed under the Apache License, Version 2.0 (the "License");
+ *    you may not use this file except in compliance with the License.
+ *    You may obtain a copy of the License at
+ *
+ *        http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *    Unless required by applicable law or agreed to in writing, software
+ *    distributed under the License is distributed on an "AS IS" BASIS,
+ *    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *    See the License for the specific language governing permissions and
+ *    limitations under the License.
+ */
+
+package model
+
+import "strconv"
+
+// DeleteRequest selects the points deleted from a measurement by time range and tag filters. All has to be set to
+// delete all points of the measurement, so an empty request never deletes anything.
+type DeleteRequest struct {
+	Time       *QueriesRequestElementTime
+	TagFilters []QueriesRequestElementTagFilter
+	All        bool
+}
+
+func (request *DeleteRequest) Validate() (errs ValidationErrors) {
+	if request.Time != nil {
+		errs = append(errs, request.Time.Validate(0, "time")...)
+	}
+	for i := range request.TagFilters {
+		errs = append(errs, request.TagFilters[i].Validate(0, "tagFilters["+strconv.Itoa(i)+"]")...)
+	}
+	if request.All && (request.Time != nil || len(request.TagFilters) > 0) {
+		errs = errs.Add(0, "all", "can not be combined with time or tagFilters")
+	}
+	if !request.All && request.Time == nil && len(request.TagFilters) == 0 {
+		errs = errs.Add(0, "all", "required if neither time nor tagFilters are set")
+	}
+	return errs
+}
+
+// DeleteResponse is returned by dry runs. Points is the number of points that would be deleted.
+type DeleteResponse struct {
+	DryRun bool  `json:"dryRun"`
+	Points int64 `json:"points"`
+}
@@ -0,0 +1,42 @@
+/*
+ *    Copyright 2021 InfAI (CC SES)
+ *
+ *    Licensed under the Apache License, Version 2.0 (the "License");
+ *    you may not use this file except in compliance with the License.
+ *    You may obtain a copy of the License at
+ *
+ *        http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *    Unless required by applicable law or agreed to in writing, software
+ *    distributed under the License is distributed on an "AS IS" BASIS,
+ *    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *    See the License for the specific language governing permissions and
+ *    limitations under the License.
+ */
+
+package model
+
+import "testing"
+
+func TestDeleteRequestValidate(t *testing.T) {
+	last := "1d"
+	invalidLast := "1 day"
+	cases := map[string]struct {
+		request DeleteRequest
+		errors  int
+	}{
+		"empty":          {DeleteRequest{}, 1},
+		"all":            {DeleteRequest{All: true}, 0},
+		"time":           {DeleteRequest{Time: &QueriesRequestElementTime{Last: &last}}, 0},
+		"tags":           {DeleteRequest{TagFilters: []QueriesRequestElementTagFilter{{Key: "device", Type: "=", Value: "d1"}}}, 0},
+		"all with time":  {DeleteRequest{All: true, Time: &QueriesRequestElementTime{Last: &last}}, 1},
+		"invalid time":   {DeleteRequest{Time: &QueriesRequestElementTime{Last: &invalidLast}}, 1},
+		"invalid filter": {DeleteRequest{TagFilters: []QueriesRequestElementTagFilter{{Key: "device", Type: ">", Value: "d1"}}}, 1},
+	}
+	for name, c := range cases {
+		errs := c.request.Validate()
+		if len(errs) != c.errors {
+			t.Error(name, errs)
+		}
+	}
+}
@@ -0,0 +1,50 @@
+/*
+ *    Copyright 2021 InfAI (CC SES)
+ *
+ *    Licensed under the Apache License, Version 2.0 (the "License");
+ *    you may not use this file except in compliance with the License.
+ *    You may obtain a copy of the License at
+ *
+ *        http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *    Unless required by applicable law or agreed to in writing, software
+ *    distributed under the License is distributed on an "AS IS" BASIS,
+ *    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *    See the License for the specific language governing permissions and
+ *    limitations under the License.
+ */
+
+package model
+
+import "strings"
+
+// ValidationError describes a single invalid field of a request body.
+// Path is the JSON path of the field, starting with the index of the request element, e.g. [2].columns[0].groupType
+type ValidationError struct {
+	Index  int    `json:"index"`
+	Path   string `json:"path"`
+	Reason string `json:"reason"`
+}
+
+type ValidationErrors []ValidationError
+
+func (errs ValidationErrors) Add(index int, path string, reason string) ValidationErrors {
+	return append(errs, ValidationError{Index: index, Path: path, Reason: reason})
+}
+
+func (errs ValidationErrors) Error() string {
+	parts := []string{}
+	for _, err := range errs {
+		parts = append(parts, err.Path+": "+err.Reason)
+	}
+	return strings.Join(parts, ", ")
+}
+
+// ProblemDetails is the application/problem+json body described in RFC 7807
+type ProblemDetails struct {
+	Type   string           `json:"type"`
+	Title  string           `json:"title"`
+	Status int              `json:"status"`
+	Detail string           `json:"detail,omitempty"`
+	Errors ValidationErrors `json:"errors,omitempty"`
+}
@@ -0,0 +1,33 @@
+/*
+ *    Copyright 2021 InfAI (CC SES)
+ *
+ *    Licensed under the Apache License, Version 2.0 (the "License");
+ *    you may not use this file except in compliance with the License.
+ *    You may obtain a copy of the License at
+ *
+ *        http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *    Unless required by applicable law or agreed to in writing, software
+ *    distributed under the License is distributed on an "AS IS" BASIS,
+ *    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *    See the License for the specific language governing permissions and
+ *    limitations under the License.
+ */
+
+package model
+
+type QueriesExplainResponse struct {
+	Format           Format                  `json:"format"`
+	OrderColumnIndex int                     `json:"orderColumnIndex"`
+	OrderDirection   Direction               `json:"orderDirection"`
+	TimeFormat       string                  `json:"timeFormat"`
+	Epoch            string                  `json:"epoch,omitempty"`
+	Elements         []QueriesExplainElement `json:"elements"`
+}
+
+type QueriesExplainElement struct {
+	Query            string    `json:"query"`
+	TimeDirection    Direction `json:"timeDirection"`
+	OrderColumnIndex int       `json:"orderColumnIndex"`
+	OrderDirection   Direction `json:"orderDirection"`
+}
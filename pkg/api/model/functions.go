@@ -0,0 +1,167 @@
+/*
+ *    Copyright 2021 InfAI (CC SES)
+ *
+ *    Licensed under the Apache License, Version 2.0 (the "License");
+ *    you may not use this file except in compliance with the License.
+ *    You may obtain a copy of the License at
+ *
+ *        http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *    Unless required by applicable law or agreed to in writing, software
+ *    distributed under the License is distributed on an "AS IS" BASIS,
+ *    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *    See the License for the specific language governing permissions and
+ *    limitations under the License.
+ */
+
+package model
+
+import (
+	"errors"
+	"math"
+	"strconv"
+	"strings"
+)
+
+type FunctionKind int
+
+const (
+	// Aggregation functions (including selectors) reduce the values of each interval to a single value
+	Aggregation FunctionKind = iota
+	// Transformation functions are applied to raw values or, if groupTime is set, to an aggregation
+	Transformation
+)
+
+type ParamType int
+
+const (
+	ParamNumber ParamType = iota
+	ParamInteger
+	ParamDuration
+)
+
+// FunctionParam declares a parameter of a function. Min and Max are inclusive bounds of numeric parameters.
+type FunctionParam struct {
+	Name     string
+	Type     ParamType
+	Optional bool
+	Min      float64
+	Max      float64
+}
+
+// Function declares an InfluxQL function usable as groupType.
+type Function struct {
+	Name   string
+	Kind   FunctionKind
+	Params []FunctionParam
+	// NoInner is set for transformations that can not be applied to an aggregation
+	NoInner bool
+}
+
+var unit = FunctionParam{Name: "unit", Type: ParamDuration, Optional: true}
+
+// Functions holds all functions usable as groupType, by name.
+var Functions = map[string]Function{}
+
+func init() {
+	for _, function := range []Function{
+		{Name: "mean", Kind: Aggregation},
+		{Name: "sum", Kind: Aggregation},
+		{Name: "count", Kind: Aggregation},
+		{Name: "median", Kind: Aggregation},
+		{Name: "min", Kind: Aggregation},
+		{Name: "max", Kind: Aggregation},
+		{Name: "first", Kind: Aggregation},
+		{Name: "last", Kind: Aggregation},
+		{Name: "stddev", Kind: Aggregation},
+		{Name: "spread", Kind: Aggregation},
+		{Name: "mode", Kind: Aggregation},
+		{Name: "distinct", Kind: Aggregation},
+		{Name: "percentile", Kind: Aggregation, Params: []FunctionParam{{Name: "N", Type: ParamNumber, Min: 0, Max: 100}}},
+		{Name: "integral", Kind: Aggregation, Params: []FunctionParam{unit}},
+		{Name: "difference", Kind: Transformation},
+		{Name: "derivative", Kind: Transformation, Params: []FunctionParam{unit}},
+		{Name: "non_negative_derivative", Kind: Transformation, Params: []FunctionParam{unit}},
+		{Name: "moving_average", Kind: Transformation, Params: []FunctionParam{{Name: "N", Type: ParamInteger, Min: 2, Max: math.MaxInt32}}},
+		{Name: "cumulative_sum", Kind: Transformation},
+		{Name: "elapsed", Kind: Transformation, Params: []FunctionParam{unit}, NoInner: true},
+	} {
+		Functions[function.Name] = function
+	}
+}
+
+// GroupType is a parsed groupType of the form <function>[-<aggregation>][-<param>...]. Inner is only set for
+// transformations of an aggregation, e.g. derivative-mean-1s. Params holds the given parameters in declaration order.
+type GroupType struct {
+	Function Function
+	Inner    *Function
+	Params   []string
+}
+
+var ErrUnknownGroupType = errors.New("unknown group type")
+
+func ParseGroupType(groupType string) (parsed GroupType, err error) {
+	parts := strings.Split(groupType, "-")
+	function, ok := Functions[parts[0]]
+	if !ok {
+		return parsed, ErrUnknownGroupType
+	}
+	parsed.Function = function
+	parts = parts[1:]
+	if function.Kind == Transformation && !function.NoInner && len(parts) > 0 {
+		inner, ok := Functions[parts[0]]
+		if ok && inner.Kind == Aggregation {
+			// parameters of the inner aggregation can not be told apart from those of the transformation
+			if inner.requiresParams() {
+				return parsed, errors.New(inner.Name + " requires parameters and can not be used as aggregation of " + function.Name)
+			}
+			parsed.Inner = &inner
+			parts = parts[1:]
+		}
+	}
+	if len(parts) > len(function.Params) {
+		return parsed, errors.New(function.Name + " accepts at most " + strconv.Itoa(len(function.Params)) + " parameters")
+	}
+	for i, param := range function.Params {
+		if i >= len(parts) {
+			if !param.Optional {
+				return parsed, errors.New(function.Name + " requires parameter " + param.Name)
+			}
+			break
+		}
+		if !param.valid(parts[i]) {
+			return parsed, errors.New("invalid parameter " + param.Name + " of " + function.Name)
+		}
+	}
+	parsed.Params = parts
+	return parsed, nil
+}
+
+func (function Function) requiresParams() bool {
+	for _, param := range function.Params {
+		if !param.Optional {
+			return true
+		}
+	}
+	return false
+}
+
+func (param FunctionParam) valid(value string) bool {
+	switch param.Type {
+	case ParamDuration:
+		return len(value) > 0 && timeIntervalValid(value)
+	case ParamInteger:
+		i, err := strconv.Atoi(value)
+		return err == nil && float64(i) >= param.Min && float64(i) <= param.Max
+	case ParamNumber:
+		f, err := strconv.ParseFloat(value, 64)
+		return err == nil && f >= param.Min && f <= param.Max
+	default:
+		return false
+	}
+}
+
+// Grouped reports if the group type is meant for queries with groupTime.
+func (groupType GroupType) Grouped() bool {
+	return groupType.Function.Kind == Aggregation || groupType.Inner != nil
+}
@@ -0,0 +1,68 @@
+/*
+ *    Copyright 2021 InfAI (CC SES)
+ *
+ *    Licensed under the Apache License, Version 2.0 (the "License");
+ *    you may not use this file except in compliance with the License.
+ *    You may obtain a copy of the License at
+ *
+ *        http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *    Unless required by applicable law or agreed to in writing, software
+ *    distributed under the License is distributed on an "AS IS" BASIS,
+ *    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *    See the License for the specific language governing permissions and
+ *    limitations under the License.
+ */
+
+package model
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseGroupType(t *testing.T) {
+	t.Run("valid", func(t *testing.T) {
+		cases := map[string]struct {
+			function string
+			inner    string
+			params   []string
+			grouped  bool
+		}{
+			"mean":                       {function: "mean", grouped: true},
+			"percentile-95":              {function: "percentile", params: []string{"95"}, grouped: true},
+			"integral-1h":                {function: "integral", params: []string{"1h"}, grouped: true},
+			"difference-max":             {function: "difference", inner: "max", grouped: true},
+			"difference":                 {function: "difference"},
+			"non_negative_derivative-1s": {function: "non_negative_derivative", params: []string{"1s"}},
+			"derivative-mean-1m":         {function: "derivative", inner: "mean", params: []string{"1m"}, grouped: true},
+			"moving_average-5":           {function: "moving_average", params: []string{"5"}},
+			"elapsed-1ms":                {function: "elapsed", params: []string{"1ms"}},
+		}
+		for groupType, expected := range cases {
+			parsed, err := ParseGroupType(groupType)
+			if err != nil {
+				t.Error(groupType, err)
+				continue
+			}
+			inner := ""
+			if parsed.Inner != nil {
+				inner = parsed.Inner.Name
+			}
+			if parsed.Function.Name != expected.function || inner != expected.inner || parsed.Grouped() != expected.grouped ||
+				(len(expected.params) > 0 && !reflect.DeepEqual(parsed.Params, expected.params)) {
+				t.Error(groupType, parsed)
+			}
+		}
+	})
+
+	t.Run("invalid", func(t *testing.T) {
+		for _, groupType := range []string{"", "avg", "mean-5", "percentile", "percentile-101", "moving_average-1",
+			"moving_average-2.5", "moving_average-percentile-5", "derivative-percentile-95", "derivative-1x", "derivative-", "elapsed-max", "difference-max-1s", "mean()"} {
+			_, err := ParseGroupType(groupType)
+			if err == nil {
+				t.Error(groupType, "expected error")
+			}
+		}
+	})
+}
@@ -0,0 +1,29 @@
+/*
+ *    Copyright 2021 InfAI (CC SES)
+ *
+ *    Licensed under the Apache License, Version 2.0 (the "License");
+ *    you may not use this file except in compliance with the License.
+ *    You may obtain a copy of the License at
+ *
+ *        http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *    Unless required by applicable law or agreed to in writing, software
+ *    distributed under the License is distributed on an "AS IS" BASIS,
+ *    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *    See the License for the specific language governing permissions and
+ *    limitations under the License.
+ */
+
+package model
+
+import "time"
+
+type ReadinessResponse struct {
+	Ready           bool      `json:"ready"`
+	Draining        bool      `json:"draining"`
+	InfluxReachable bool      `json:"influxReachable"`
+	InfluxVersion   string    `json:"influxVersion,omitempty"`
+	InfluxLatencyMs float64   `json:"influxLatencyMs"`
+	Error           string    `json:"error,omitempty"`
+	CheckedAt       time.Time `json:"checkedAt"`
+}
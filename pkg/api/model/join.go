@@ -0,0 +1,153 @@
+/*
+ *    Copyright 2021 InfAI (CC SES)
+ *
+ *    Licensed under the Apache License, Version 2.0 (the "License");
+ *    you may not use this file except in compliance with the License.
+ *    You may obtain a copy of the License at
+ *
+ *        http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *    Unless required by applicable law or agreed to in writing, software
+ *    distributed under the License is distributed on an "AS IS" BASIS,
+ *    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *    See the License for the specific language governing permissions and
+ *    limitations under the License.
+ */
+
+package model
+
+import (
+	"encoding/json"
+	"strconv"
+	"time"
+)
+
+// JoinStrategy decides how cells of a table without a value at the timestamp of their row are filled.
+type JoinStrategy string
+
+const (
+	JoinNone         JoinStrategy = "none"
+	JoinForwardFill  JoinStrategy = "forward_fill"
+	JoinBackwardFill JoinStrategy = "backward_fill"
+	JoinLinear       JoinStrategy = "linear"
+	JoinNearest      JoinStrategy = "nearest"
+)
+
+func (strategy JoinStrategy) Valid() bool {
+	switch strategy {
+	case JoinNone, JoinForwardFill, JoinBackwardFill, JoinLinear, JoinNearest:
+		return true
+	default:
+		return false
+	}
+}
+
+// FillGaps2D fills nil values of the given columns with values of the same column in other rows. The first column of
+// each row has to be a time.Time and rows have to be sorted by time ascending. Only values that were set before
+// filling are used as source. A source is only used if it is at most tolerance away from the filled row, a tolerance
+// of 0 allows any distance. Linear interpolation requires numeric values on both sides, which may be at most
+// tolerance apart. Other values are left nil.
+func FillGaps2D(table [][]interface{}, columns []int, strategy JoinStrategy, tolerance time.Duration) {
+	if strategy == JoinNone || strategy == "" {
+		return
+	}
+	known := make([]int, 0, len(table))
+	for _, column := range columns {
+		known = known[:0]
+		for i := range table {
+			if table[i][column] != nil {
+				known = append(known, i)
+			}
+		}
+		if len(known) == 0 {
+			continue
+		}
+		next := 0 // index in known of the first source after the current row
+		for i := range table {
+			if next < len(known) && known[next] <= i {
+				next++
+			}
+			if table[i][column] != nil {
+				continue
+			}
+			prev := -1
+			if next > 0 {
+				prev = known[next-1]
+			}
+			following := -1
+			if next < len(known) {
+				following = known[next]
+			}
+			table[i][column] = fillValue(table, i, column, prev, following, strategy, tolerance)
+		}
+	}
+}
+
+func fillValue(table [][]interface{}, row int, column int, prev int, next int, strategy JoinStrategy, tolerance time.Duration) interface{} {
+	t := table[row][0].(time.Time)
+	prevOk := prev >= 0 && within(table[prev][0].(time.Time), t, tolerance)
+	nextOk := next >= 0 && within(table[next][0].(time.Time), t, tolerance)
+	switch strategy {
+	case JoinForwardFill:
+		if prevOk {
+			return table[prev][column]
+		}
+	case JoinBackwardFill:
+		if nextOk {
+			return table[next][column]
+		}
+	case JoinNearest:
+		if prevOk && nextOk {
+			if t.Sub(table[prev][0].(time.Time)) <= table[next][0].(time.Time).Sub(t) {
+				return table[prev][column]
+			}
+			return table[next][column]
+		}
+		if prevOk {
+			return table[prev][column]
+		}
+		if nextOk {
+			return table[next][column]
+		}
+	case JoinLinear:
+		if prev < 0 || next < 0 || !within(table[prev][0].(time.Time), table[next][0].(time.Time), tolerance) {
+			return nil
+		}
+		v0, ok0 := toFloat(table[prev][column])
+		v1, ok1 := toFloat(table[next][column])
+		if !ok0 || !ok1 {
+			return nil
+		}
+		t0 := table[prev][0].(time.Time)
+		t1 := table[next][0].(time.Time)
+		if t1.Equal(t0) {
+			return table[prev][column]
+		}
+		value := v0 + (v1-v0)*float64(t.Sub(t0))/float64(t1.Sub(t0))
+		return json.Number(strconv.FormatFloat(value, 'f', -1, 64))
+	}
+	return nil
+}
+
+func within(source time.Time, t time.Time, tolerance time.Duration) bool {
+	if tolerance == 0 {
+		return true
+	}
+	distance := t.Sub(source)
+	if distance < 0 {
+		distance = -distance
+	}
+	return distance <= tolerance
+}
+
+func toFloat(value interface{}) (float64, bool) {
+	switch v := value.(type) {
+	case json.Number:
+		f, err := v.Float64()
+		return f, err == nil
+	case float64:
+		return v, true
+	default:
+		return 0, false
+	}
+}
@@ -0,0 +1,85 @@
+/*
+ *    Copyright 2021 InfAI (CC SES)
+ *
+ *    Licensed under the Apache License, Version 2.0 (the "License");
+ *    you may not use this file except in compliance with the License.
+ *    You may obtain a copy of the License at
+ *
+ *        http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *    Unless required by applicable law or agreed to in writing, software
+ *    distributed under the License is distributed on an "AS IS" BASIS,
+ *    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *    See the License for the specific language governing permissions and
+ *    limitations under the License.
+ */
+
+package model
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestFillGaps2D(t *testing.T) {
+	start := time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC)
+	table := func() [][]interface{} {
+		return [][]interface{}{
+			{start, json.Number("0"), "a"},
+			{start.Add(time.Minute), nil, nil},
+			{start.Add(3 * time.Minute), nil, nil},
+			{start.Add(4 * time.Minute), json.Number("8"), "b"},
+			{start.Add(10 * time.Minute), nil, nil},
+		}
+	}
+	column := func(table [][]interface{}, index int) (values []interface{}) {
+		for _, row := range table {
+			values = append(values, row[index])
+		}
+		return values
+	}
+
+	cases := []struct {
+		strategy  JoinStrategy
+		tolerance time.Duration
+		numbers   []interface{}
+		strings   []interface{}
+	}{
+		{JoinNone, 0,
+			[]interface{}{json.Number("0"), nil, nil, json.Number("8"), nil},
+			[]interface{}{"a", nil, nil, "b", nil}},
+		{JoinForwardFill, 0,
+			[]interface{}{json.Number("0"), json.Number("0"), json.Number("0"), json.Number("8"), json.Number("8")},
+			[]interface{}{"a", "a", "a", "b", "b"}},
+		{JoinForwardFill, 2 * time.Minute,
+			[]interface{}{json.Number("0"), json.Number("0"), nil, json.Number("8"), nil},
+			[]interface{}{"a", "a", nil, "b", nil}},
+		{JoinBackwardFill, 0,
+			[]interface{}{json.Number("0"), json.Number("8"), json.Number("8"), json.Number("8"), nil},
+			[]interface{}{"a", "b", "b", "b", nil}},
+		{JoinNearest, 0,
+			[]interface{}{json.Number("0"), json.Number("0"), json.Number("8"), json.Number("8"), json.Number("8")},
+			[]interface{}{"a", "a", "b", "b", "b"}},
+		{JoinNearest, time.Minute,
+			[]interface{}{json.Number("0"), json.Number("0"), json.Number("8"), json.Number("8"), nil},
+			[]interface{}{"a", "a", "b", "b", nil}},
+		{JoinLinear, 0,
+			[]interface{}{json.Number("0"), json.Number("2"), json.Number("6"), json.Number("8"), nil},
+			[]interface{}{"a", nil, nil, "b", nil}},
+		{JoinLinear, 4 * time.Minute,
+			[]interface{}{json.Number("0"), json.Number("2"), json.Number("6"), json.Number("8"), nil},
+			[]interface{}{"a", nil, nil, "b", nil}},
+		{JoinLinear, 3 * time.Minute,
+			[]interface{}{json.Number("0"), nil, nil, json.Number("8"), nil},
+			[]interface{}{"a", nil, nil, "b", nil}},
+	}
+	for _, c := range cases {
+		actual := table()
+		FillGaps2D(actual, []int{1, 2}, c.strategy, c.tolerance)
+		if !reflect.DeepEqual(column(actual, 1), c.numbers) || !reflect.DeepEqual(column(actual, 2), c.strings) {
+			t.Error(c.strategy, c.tolerance, actual)
+		}
+	}
+}
@@ -0,0 +1,26 @@
+/*
+ *    Copyright 2021 InfAI (CC SES)
+ *
+ *    Licensed under the Apache License, Version 2.0 (the "License");
+ *    you may not use this file except in compliance with the License.
+ *    You may obtain a copy of the License at
+ *
+ *        http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *    Unless required by applicable law or agreed to in writing, software
+ *    distributed under the License is distributed on an "AS IS" BASIS,
+ *    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *    See the License for the specific language governing permissions and
+ *    limitations under the License.
+ */
+
+package model
+
+// TimeValuePair is a single value of a /last-values response. Time is formatted as requested by the query params
+// time_format or epoch, null if there is no value. Error is only set if the request element failed.
+type TimeValuePair struct {
+	Time  interface{}       `json:"time"`
+	Value interface{}       `json:"value"`
+	Tags  map[string]string `json:"tags,omitempty"`
+	Error string            `json:"error,omitempty"`
+}
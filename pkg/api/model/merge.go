@@ -0,0 +1,123 @@
+/*
+ *    Copyright 2021 InfAI (CC SES)
+ *
+ *    Licensed under the Apache License, Version 2.0 (the "License");
+ *    you may not use this file except in compliance with the License.
+ *    You may obtain a copy of the License at
+ *
+ *        http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *    Unless required by applicable law or agreed to in writing, software
+ *    distributed under the License is distributed on an "AS IS" BASIS,
+ *    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *    See the License for the specific language governing permissions and
+ *    limitations under the License.
+ */
+
+package model
+
+import (
+	"container/heap"
+	"sort"
+	"time"
+)
+
+// MergeByTime joins multiple series into a single table with a k-way merge in O(rows * log(series)). The first column
+// of each row has to be a time.Time and each series has to be sorted by time ascending (see SortByTime). The remaining
+// columns of a row of series i are copied to the table starting at offsets[i]. Rows of different series with equal
+// timestamps are joined, multiple rows with the same timestamp within one series create multiple table rows.
+// Each table row starts as a copy of template, the resulting table is sorted by time ascending.
+func MergeByTime(series [][][]interface{}, offsets []int, template []interface{}) (table [][]interface{}) {
+	total := 0
+	cursors := &mergeCursors{}
+	for i := range series {
+		total += len(series[i])
+		if len(series[i]) > 0 {
+			cursors.items = append(cursors.items, mergeCursor{series: i, time: series[i][0][0].(time.Time)})
+		}
+	}
+	if total == 0 {
+		return nil
+	}
+	heap.Init(cursors)
+	table = make([][]interface{}, 0, total)
+	joined := []mergeCursor{}
+	for cursors.Len() > 0 {
+		first := heap.Pop(cursors).(mergeCursor)
+		joined = append(joined[:0], first)
+		for cursors.Len() > 0 && cursors.items[0].time.Equal(first.time) {
+			joined = append(joined, heap.Pop(cursors).(mergeCursor))
+		}
+
+		tableRow := make([]interface{}, len(template))
+		copy(tableRow, template)
+		tableRow[0] = first.time
+		for _, cursor := range joined {
+			copy(tableRow[offsets[cursor.series]:], series[cursor.series][cursor.row][1:])
+			cursor.row++
+			if cursor.row < len(series[cursor.series]) {
+				cursor.time = series[cursor.series][cursor.row][0].(time.Time)
+				heap.Push(cursors, cursor)
+			}
+		}
+		table = append(table, tableRow)
+	}
+	return table
+}
+
+// SortByTime sorts rows by the time.Time in their first column ascending. Rows that are already sorted ascending
+// or descending are handled in linear time.
+func SortByTime(rows [][]interface{}) {
+	before := func(i, j int) bool {
+		return rows[i][0].(time.Time).Before(rows[j][0].(time.Time))
+	}
+	if sort.SliceIsSorted(rows, before) {
+		return
+	}
+	after := func(i, j int) bool {
+		return rows[i][0].(time.Time).After(rows[j][0].(time.Time))
+	}
+	if sort.SliceIsSorted(rows, after) {
+		for i, j := 0, len(rows)-1; i < j; i, j = i+1, j-1 {
+			rows[i], rows[j] = rows[j], rows[i]
+		}
+		return
+	}
+	sort.SliceStable(rows, before)
+}
+
+type mergeCursor struct {
+	series int
+	row    int
+	time   time.Time
+}
+
+// mergeCursors is a min heap of the next row of each series, ties are broken by the series index
+type mergeCursors struct {
+	items []mergeCursor
+}
+
+func (this *mergeCursors) Len() int {
+	return len(this.items)
+}
+
+func (this *mergeCursors) Less(i, j int) bool {
+	if this.items[i].time.Equal(this.items[j].time) {
+		return this.items[i].series < this.items[j].series
+	}
+	return this.items[i].time.Before(this.items[j].time)
+}
+
+func (this *mergeCursors) Swap(i, j int) {
+	this.items[i], this.items[j] = this.items[j], this.items[i]
+}
+
+func (this *mergeCursors) Push(x interface{}) {
+	this.items = append(this.items, x.(mergeCursor))
+}
+
+func (this *mergeCursors) Pop() interface{} {
+	last := this.items[len(this.items)-1]
+	this.items = this.items[:len(this.items)-1]
+	return last
+}
@@ -0,0 +1,99 @@
+/*
+ *    Copyright 2021 InfAI (CC SES)
+ *
+ *    Licensed under the Apache License, Version 2.0 (the "License");
+ *    you may not use this file except in compliance with the License.
+ *    You may obtain a copy of the License at
+ *
+ *        http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *    Unless required by applicable law or agreed to in writing, software
+ *    distributed under the License is distributed on an "AS IS" BASIS,
+ *    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *    See the License for the specific language governing permissions and
+ *    limitations under the License.
+ */
+
+package model
+
+import (
+	"reflect"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestMergeByTime(t *testing.T) {
+	t0 := time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC)
+	t1 := t0.Add(time.Minute)
+	t2 := t0.Add(2 * time.Minute)
+
+	t.Run("merge", func(t *testing.T) {
+		series := [][][]interface{}{
+			{{t0, "a0"}, {t2, "a2"}},
+			{{t1, "b1", "tag"}, {t2, "b2", "tag"}, {t2, "b2'", "tag"}},
+			{},
+			{{t0, "d0"}},
+		}
+		actual := MergeByTime(series, []int{1, 2, 4, 4}, []interface{}{nil, nil, nil, "tag", nil})
+		expected := [][]interface{}{
+			{t0, "a0", nil, "tag", "d0"},
+			{t1, nil, "b1", "tag", nil},
+			{t2, "a2", "b2", "tag", nil},
+			{t2, nil, "b2'", "tag", nil},
+		}
+		if !reflect.DeepEqual(actual, expected) {
+			t.Error("\nexpected\n", expected, "\nactual\n", actual)
+		}
+	})
+
+	t.Run("empty", func(t *testing.T) {
+		actual := MergeByTime([][][]interface{}{{}, {}}, []int{1, 2}, []interface{}{nil, nil, nil})
+		if actual != nil {
+			t.Error(actual)
+		}
+	})
+
+	t.Run("sort", func(t *testing.T) {
+		for _, rows := range [][][]interface{}{
+			{{t0}, {t1}, {t2}},
+			{{t2}, {t1}, {t0}},
+			{{t1}, {t2}, {t0}},
+		} {
+			SortByTime(rows)
+			if !reflect.DeepEqual(rows, [][]interface{}{{t0}, {t1}, {t2}}) {
+				t.Error(rows)
+			}
+		}
+	})
+}
+
+func BenchmarkMergeByTime(b *testing.B) {
+	for _, size := range []struct {
+		series int
+		rows   int
+	}{{10, 10000}, {100, 1000}, {1000, 100}, {10, 100000}} {
+		b.Run(strconv.Itoa(size.series)+"x"+strconv.Itoa(size.rows), func(b *testing.B) {
+			series, offsets := benchmarkSeries(size.series, size.rows)
+			template := make([]interface{}, size.series+1)
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				MergeByTime(series, offsets, template)
+			}
+		})
+	}
+}
+
+// every series has a value each second, with a different offset per series, so some timestamps are shared
+func benchmarkSeries(count int, rows int) (series [][][]interface{}, offsets []int) {
+	start := time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC)
+	for i := 0; i < count; i++ {
+		values := make([][]interface{}, rows)
+		for j := range values {
+			values[j] = []interface{}{start.Add(time.Duration(j)*time.Second + time.Duration(i%4)*time.Second/2), float64(j)}
+		}
+		series = append(series, values)
+		offsets = append(offsets, i+1)
+	}
+	return series, offsets
+}
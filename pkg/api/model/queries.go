@@ -17,57 +17,133 @@
 package model
 
 import (
+	"math"
 	"regexp"
+	"strconv"
 	"time"
 )
 
 type QueriesRequestElement struct {
 	Measurement      string
+	RetentionPolicy  *string
 	Time             *QueriesRequestElementTime
 	Limit            *int
+	Offset           *int
+	Cursor           *string
 	Columns          []QueriesRequestElementColumn
 	Filters          *[]QueriesRequestElementFilter
+	TagFilters       *[]QueriesRequestElementTagFilter
+	FilterExpression *QueriesRequestElementFilterExpression
 	GroupTime        *string
+	GroupTags        []string
+	Fill             interface{}
+	Timezone         *string
 	OrderColumnIndex *int
 	OrderDirection   *Direction
 }
 
-func (element *QueriesRequestElement) Valid(format Format) bool {
+// Validate checks the element at position index of a request. Defaults are set for valid elements.
+func (element *QueriesRequestElement) Validate(index int, format Format) (errs ValidationErrors) {
+	path := "[" + strconv.Itoa(index) + "]"
 	if len(element.Measurement) == 0 {
-		return false
+		errs = errs.Add(index, path+".measurement", "must not be empty")
 	}
-	if element.Time != nil && !element.Time.Valid() {
-		return false
+	if element.RetentionPolicy != nil && len(*element.RetentionPolicy) == 0 {
+		errs = errs.Add(index, path+".retentionPolicy", "must not be empty")
 	}
-	if element.Limit != nil && (*element.Limit < 1 || element.GroupTime != nil) {
-		return false
+	if element.Time != nil {
+		errs = append(errs, element.Time.Validate(index, path+".time")...)
 	}
-	if len(element.Columns) == 0 {
-		return false
+	if element.Limit != nil {
+		if *element.Limit < 1 {
+			errs = errs.Add(index, path+".limit", "must be greater than 0")
+		}
+		if element.GroupTime != nil {
+			errs = errs.Add(index, path+".limit", "can not be combined with groupTime")
+		}
 	}
-	for _, column := range element.Columns {
-		if !column.Valid(element.GroupTime != nil) {
-			return false
+	if element.Offset != nil {
+		if *element.Offset < 0 {
+			errs = errs.Add(index, path+".offset", "must not be negative")
+		}
+		if element.GroupTime != nil {
+			errs = errs.Add(index, path+".offset", "can not be combined with groupTime")
 		}
 	}
+	if element.Cursor != nil {
+		if _, err := ParseCursor(*element.Cursor); err != nil {
+			errs = errs.Add(index, path+".cursor", err.Error())
+		}
+		if element.GroupTime != nil {
+			errs = errs.Add(index, path+".cursor", "can not be combined with groupTime")
+		}
+		if element.Offset != nil {
+			errs = errs.Add(index, path+".cursor", "can not be combined with offset")
+		}
+	}
+	if len(element.Columns) == 0 {
+		errs = errs.Add(index, path+".columns", "must not be empty")
+	}
+	for i, column := range element.Columns {
+		errs = append(errs, column.Validate(index, path+".columns["+strconv.Itoa(i)+"]", element.GroupTime != nil)...)
+	}
 	if element.Filters != nil {
-		for _, filter := range *element.Filters {
-			if !filter.Valid() {
-				return false
-			}
+		for i, filter := range *element.Filters {
+			errs = append(errs, filter.Validate(index, path+".filters["+strconv.Itoa(i)+"]")...)
+		}
+	}
+	if element.TagFilters != nil {
+		for i, filter := range *element.TagFilters {
+			errs = append(errs, filter.Validate(index, path+".tagFilters["+strconv.Itoa(i)+"]")...)
+		}
+	}
+	if element.FilterExpression != nil {
+		nodes := 0
+		errs = append(errs, element.FilterExpression.Validate(index, path+".filterExpression", 1, &nodes)...)
+		if nodes > MaxFilterExpressionNodes {
+			errs = errs.Add(index, path+".filterExpression", "must not contain more than "+strconv.Itoa(MaxFilterExpressionNodes)+" nodes")
 		}
 	}
+	knownTags := map[string]struct{}{}
+	for i, tag := range element.GroupTags {
+		if len(tag) == 0 {
+			errs = errs.Add(index, path+".groupTags["+strconv.Itoa(i)+"]", "must not be empty")
+		}
+		if _, ok := knownTags[tag]; ok {
+			errs = errs.Add(index, path+".groupTags["+strconv.Itoa(i)+"]", "duplicate tag")
+		}
+		knownTags[tag] = struct{}{}
+	}
 	if element.GroupTime != nil && !timeIntervalValid(*element.GroupTime) {
-		return false
+		errs = errs.Add(index, path+".groupTime", "invalid time interval")
 	}
-	if (element.OrderColumnIndex != nil || element.OrderDirection != nil) && format != PerQuery {
-		return false
+	if element.Fill != nil {
+		if element.GroupTime == nil {
+			errs = errs.Add(index, path+".fill", "requires groupTime")
+		}
+		if !fillValid(element.Fill) {
+			errs = errs.Add(index, path+".fill", "must be one of null, none, previous, linear or a number")
+		}
+	}
+	if element.Timezone != nil {
+		if _, err := time.LoadLocation(*element.Timezone); err != nil || *element.Timezone == "Local" {
+			errs = errs.Add(index, path+".timezone", "unknown timezone")
+		}
+	}
+	if element.OrderColumnIndex != nil && format != PerQuery {
+		errs = errs.Add(index, path+".orderColumnIndex", "only allowed in format "+string(PerQuery))
+	}
+	if element.OrderDirection != nil && format != PerQuery {
+		errs = errs.Add(index, path+".orderDirection", "only allowed in format "+string(PerQuery))
 	}
 	if element.OrderDirection != nil && *element.OrderDirection != Asc && *element.OrderDirection != Desc {
-		return false
+		errs = errs.Add(index, path+".orderDirection", "must be one of asc, desc")
 	}
-	if element.OrderColumnIndex != nil && (*element.OrderColumnIndex < 1 || *element.OrderColumnIndex > len(element.Columns)) {
-		return false
+	if element.OrderColumnIndex != nil && (*element.OrderColumnIndex < 1 || *element.OrderColumnIndex > len(element.Columns)+len(element.GroupTags)) {
+		errs = errs.Add(index, path+".orderColumnIndex", "must reference a requested column")
+	}
+	if len(errs) > 0 {
+		return errs
 	}
 	if element.OrderColumnIndex == nil {
 		zero := 0
@@ -77,7 +153,7 @@ func (element *QueriesRequestElement) Valid(format Format) bool {
 		desc := Desc
 		element.OrderDirection = &desc
 	}
-	return true
+	return nil
 }
 
 type QueriesRequestElementTime struct {
@@ -87,35 +163,34 @@ type QueriesRequestElementTime struct {
 	End   *string
 }
 
-func (elementTime *QueriesRequestElementTime) Valid() bool {
+func (elementTime *QueriesRequestElementTime) Validate(index int, path string) (errs ValidationErrors) {
 	if elementTime.Last != nil {
 		if elementTime.Start != nil || elementTime.End != nil || elementTime.Ahead != nil {
-			return false
+			errs = errs.Add(index, path, "use either last or ahead or start and end")
 		}
 		if !timeIntervalValid(*elementTime.Last) {
-			return false
+			errs = errs.Add(index, path+".last", "invalid time interval")
 		}
 	} else if elementTime.Ahead != nil {
-		if elementTime.Start != nil || elementTime.End != nil || elementTime.Last != nil {
-			return false
+		if elementTime.Start != nil || elementTime.End != nil {
+			errs = errs.Add(index, path, "use either last or ahead or start and end")
 		}
 		if !timeIntervalValid(*elementTime.Ahead) {
-			return false
+			errs = errs.Add(index, path+".ahead", "invalid time interval")
 		}
 	} else {
-		if elementTime.Start == nil || elementTime.End == nil {
-			return false
+		if elementTime.Start == nil {
+			errs = errs.Add(index, path+".start", "required if neither last nor ahead are set")
+		} else if _, err := time.Parse(time.RFC3339, *elementTime.Start); err != nil {
+			errs = errs.Add(index, path+".start", "must be formatted as rfc3339")
 		}
-		_, err := time.Parse(time.RFC3339, *elementTime.Start)
-		if err != nil {
-			return false
-		}
-		_, err = time.Parse(time.RFC3339, *elementTime.End)
-		if err != nil {
-			return false
+		if elementTime.End == nil {
+			errs = errs.Add(index, path+".end", "required if neither last nor ahead are set")
+		} else if _, err := time.Parse(time.RFC3339, *elementTime.End); err != nil {
+			errs = errs.Add(index, path+".end", "must be formatted as rfc3339")
 		}
 	}
-	return true
+	return errs
 }
 
 type QueriesRequestElementColumn struct {
@@ -124,26 +199,25 @@ type QueriesRequestElementColumn struct {
 	Math      *string
 }
 
-func (elementColumn *QueriesRequestElementColumn) Valid(hasTime bool) bool {
+func (elementColumn *QueriesRequestElementColumn) Validate(index int, path string, hasTime bool) (errs ValidationErrors) {
 	if len(elementColumn.Name) == 0 {
-		return false
-	}
-	if elementColumn.GroupType != nil && !hasTime {
-		return false
+		errs = errs.Add(index, path+".name", "must not be empty")
 	}
 	if elementColumn.GroupType != nil {
-		allowedTypes := []interface{}{}
-		allowedTypes = append(allowedTypes, "mean", "sum", "count", "median", "min", "max", "first", "last",
-			"difference-first", "difference-last", "difference-min", "difference-max", "difference-count", "difference-mean",
-			"difference-sum", "difference-median")
-		if !ElementInArray(*elementColumn.GroupType, allowedTypes) {
-			return false
+		groupType, err := ParseGroupType(*elementColumn.GroupType)
+		if !hasTime && (err != nil || groupType.Grouped()) {
+			errs = errs.Add(index, path+".groupType", "requires groupTime")
+		}
+		if err != nil {
+			errs = errs.Add(index, path+".groupType", err.Error())
+		} else if hasTime && !groupType.Grouped() {
+			errs = errs.Add(index, path+".groupType", "transformations require an aggregation if groupTime is set")
 		}
 	}
 	if elementColumn.Math != nil && !mathValid(*elementColumn.Math) {
-		return false
+		errs = errs.Add(index, path+".math", "invalid math operation")
 	}
-	return true
+	return errs
 }
 
 type QueriesRequestElementFilter struct {
@@ -153,13 +227,132 @@ type QueriesRequestElementFilter struct {
 	Value  interface{}
 }
 
-func (filter *QueriesRequestElementFilter) Valid() bool {
+func (filter *QueriesRequestElementFilter) Validate(index int, path string) (errs ValidationErrors) {
+	if len(filter.Column) == 0 {
+		errs = errs.Add(index, path+".column", "must not be empty")
+	}
 	if filter.Math != nil && !mathValid(*filter.Math) {
-		return false
+		errs = errs.Add(index, path+".math", "invalid math operation")
 	}
 	allowedTypes := []interface{}{}
 	allowedTypes = append(allowedTypes, "=", "<>", "!=", ">", ">=", "<", "<=")
-	return ElementInArray(filter.Type, allowedTypes) && len(filter.Column) > 0 && filter.Value != nil
+	if !ElementInArray(filter.Type, allowedTypes) {
+		errs = errs.Add(index, path+".type", "unknown filter type")
+	}
+	if filter.Value == nil {
+		errs = errs.Add(index, path+".value", "must not be null")
+	}
+	return errs
+}
+
+// QueriesRequestElementTagFilter compares the tag Key with Value. Value is a regular expression for the types =~ and !~
+type QueriesRequestElementTagFilter struct {
+	Key   string
+	Type  string
+	Value string
+}
+
+func (filter *QueriesRequestElementTagFilter) Validate(index int, path string) (errs ValidationErrors) {
+	if len(filter.Key) == 0 {
+		errs = errs.Add(index, path+".key", "must not be empty")
+	}
+	switch filter.Type {
+	case "=", "!=", "<>":
+	case "=~", "!~":
+		if _, err := regexp.Compile(filter.Value); err != nil {
+			errs = errs.Add(index, path+".value", "invalid regular expression")
+		}
+	default:
+		errs = errs.Add(index, path+".type", "unknown tag filter type")
+	}
+	return errs
+}
+
+// QueriesRequestElementFilterExpression is a node of a boolean filter expression. Exactly one of And, Or, Not,
+// Filter and TagFilter has to be set.
+type QueriesRequestElementFilterExpression struct {
+	And       []QueriesRequestElementFilterExpression
+	Or        []QueriesRequestElementFilterExpression
+	Not       *QueriesRequestElementFilterExpression
+	Filter    *QueriesRequestElementFilter
+	TagFilter *QueriesRequestElementTagFilter
+}
+
+const (
+	MaxFilterExpressionDepth = 8
+	MaxFilterExpressionNodes = 100
+)
+
+// Validate checks the node at the given depth (starting at 1) and all of its children. nodes counts the visited nodes.
+func (expression *QueriesRequestElementFilterExpression) Validate(index int, path string, depth int, nodes *int) (errs ValidationErrors) {
+	*nodes++
+	if depth > MaxFilterExpressionDepth {
+		return errs.Add(index, path, "must not be nested deeper than "+strconv.Itoa(MaxFilterExpressionDepth)+" levels")
+	}
+	set := 0
+	if expression.And != nil {
+		set++
+	}
+	if expression.Or != nil {
+		set++
+	}
+	if expression.Not != nil {
+		set++
+	}
+	if expression.Filter != nil {
+		set++
+	}
+	if expression.TagFilter != nil {
+		set++
+	}
+	if set != 1 {
+		return errs.Add(index, path, "exactly one of and, or, not, filter, tagFilter has to be set")
+	}
+	switch {
+	case expression.And != nil:
+		errs = append(errs, validateFilterExpressions(expression.And, index, path+".and", depth, nodes)...)
+	case expression.Or != nil:
+		errs = append(errs, validateFilterExpressions(expression.Or, index, path+".or", depth, nodes)...)
+	case expression.Not != nil:
+		errs = append(errs, expression.Not.Validate(index, path+".not", depth+1, nodes)...)
+	case expression.Filter != nil:
+		errs = append(errs, expression.Filter.Validate(index, path+".filter")...)
+	case expression.TagFilter != nil:
+		errs = append(errs, expression.TagFilter.Validate(index, path+".tagFilter")...)
+	}
+	return errs
+}
+
+func validateFilterExpressions(expressions []QueriesRequestElementFilterExpression, index int, path string, depth int, nodes *int) (errs ValidationErrors) {
+	if len(expressions) == 0 {
+		return errs.Add(index, path, "must not be empty")
+	}
+	for i := range expressions {
+		if *nodes > MaxFilterExpressionNodes {
+			break
+		}
+		errs = append(errs, expressions[i].Validate(index, path+"["+strconv.Itoa(i)+"]", depth+1, nodes)...)
+	}
+	return errs
+}
+
+const (
+	FillNull     = "null"
+	FillNone     = "none"
+	FillPrevious = "previous"
+	FillLinear   = "linear"
+)
+
+// fill is either one of the fill options or a number, which replaces missing values
+func fillValid(fill interface{}) bool {
+	switch v := fill.(type) {
+	case string:
+		return v == FillNull || v == FillNone || v == FillPrevious || v == FillLinear
+	case float64:
+		return !math.IsNaN(v) && !math.IsInf(v, 0)
+	default:
+		return false
+	}
 }
 
 func mathValid(math string) bool {
@@ -167,9 +360,11 @@ func mathValid(math string) bool {
 	return len(mathMatcher.FindString(math)) == len(math)
 }
 
+// time interval with a single unit as used by InfluxQL, e.g. 30d
+var timeIntervalMatcher = regexp.MustCompile("^(\\d+)(ns|u|µ|ms|s|m|h|d|w)$")
+
 func timeIntervalValid(timeInterval string) bool {
-	timeMatcher := regexp.MustCompile("\\d+(ns|u|µ|ms|s|m|h|d|w)")
-	return len(timeMatcher.FindString(timeInterval)) == len(timeInterval)
+	return timeIntervalMatcher.MatchString(timeInterval)
 }
 
 type Format string
@@ -177,6 +372,8 @@ type Format string
 const (
 	PerQuery Format = "per_query"
 	Table    Format = "table"
+	CSV      Format = "csv"
+	NDJSON   Format = "ndjson"
 )
 
 type Direction string
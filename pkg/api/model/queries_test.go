@@ -0,0 +1,247 @@
+/*
+ *    Copyright 2021 InfAI (CC SES)
+ *
+ *    Licensed under the Apache License, Version 2.0 (the "License");
+ *    you may not use this file except in compliance with the License.
+ *    You may obtain a copy of the License at
+ *
+ *        http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *    Unless required by applicable law or agreed to in writing, software
+ *    distributed under the License is distributed on an "AS IS" BASIS,
+ *    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *    See the License for the specific language governing permissions and
+ *    limitations under the License.
+ */
+
+package model
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestQueriesRequestElementValidate(t *testing.T) {
+	t.Run("valid", func(t *testing.T) {
+		last := "1d"
+		element := QueriesRequestElement{
+			Measurement: "m1",
+			Time:        &QueriesRequestElementTime{Last: &last},
+			Columns:     []QueriesRequestElementColumn{{Name: "c1"}},
+		}
+		errs := element.Validate(0, PerQuery)
+		if len(errs) != 0 {
+			t.Error(errs)
+		}
+		if element.OrderColumnIndex == nil || *element.OrderColumnIndex != 0 || element.OrderDirection == nil || *element.OrderDirection != Desc {
+			t.Error("defaults not set")
+		}
+	})
+
+	t.Run("invalid", func(t *testing.T) {
+		last := "1d"
+		start := "yesterday"
+		groupType := "avg"
+		math := "+x"
+		limit := 0
+		orderColumnIndex := 2
+		element := QueriesRequestElement{
+			Time:  &QueriesRequestElementTime{Last: &last, Start: &start},
+			Limit: &limit,
+			Columns: []QueriesRequestElementColumn{
+				{Name: "c1"},
+				{Name: "c2", GroupType: &groupType, Math: &math},
+			},
+			Filters:          &[]QueriesRequestElementFilter{{Column: "c1", Type: "~"}},
+			OrderColumnIndex: &orderColumnIndex,
+		}
+		expected := ValidationErrors{
+			{Index: 2, Path: "[2].measurement", Reason: "must not be empty"},
+			{Index: 2, Path: "[2].time", Reason: "use either last or ahead or start and end"},
+			{Index: 2, Path: "[2].limit", Reason: "must be greater than 0"},
+			{Index: 2, Path: "[2].columns[1].groupType", Reason: "requires groupTime"},
+			{Index: 2, Path: "[2].columns[1].groupType", Reason: "unknown group type"},
+			{Index: 2, Path: "[2].columns[1].math", Reason: "invalid math operation"},
+			{Index: 2, Path: "[2].filters[0].type", Reason: "unknown filter type"},
+			{Index: 2, Path: "[2].filters[0].value", Reason: "must not be null"},
+			{Index: 2, Path: "[2].orderColumnIndex", Reason: "only allowed in format per_query"},
+		}
+		actual := element.Validate(2, Table)
+		if !reflect.DeepEqual(actual, expected) {
+			t.Error("\nexpected\n", expected, "\nactual\n", actual)
+		}
+		if element.OrderDirection != nil {
+			t.Error("defaults set on invalid element")
+		}
+	})
+
+	t.Run("tags", func(t *testing.T) {
+		last := "1d"
+		orderColumnIndex := 2
+		element := QueriesRequestElement{
+			Measurement: "m1",
+			Time:        &QueriesRequestElementTime{Last: &last},
+			Columns:     []QueriesRequestElementColumn{{Name: "c1"}},
+			TagFilters: &[]QueriesRequestElementTagFilter{
+				{Key: "device", Type: "=~", Value: "^sensor-[0-9]+$"},
+				{Key: "", Type: "!=", Value: "x"},
+				{Key: "device", Type: "=~", Value: "(unclosed"},
+				{Key: "device", Type: ">", Value: "x"},
+			},
+			GroupTags:        []string{"device", "", "device"},
+			OrderColumnIndex: &orderColumnIndex,
+		}
+		expected := ValidationErrors{
+			{Index: 0, Path: "[0].tagFilters[1].key", Reason: "must not be empty"},
+			{Index: 0, Path: "[0].tagFilters[2].value", Reason: "invalid regular expression"},
+			{Index: 0, Path: "[0].tagFilters[3].type", Reason: "unknown tag filter type"},
+			{Index: 0, Path: "[0].groupTags[1]", Reason: "must not be empty"},
+			{Index: 0, Path: "[0].groupTags[2]", Reason: "duplicate tag"},
+		}
+		actual := element.Validate(0, PerQuery)
+		if !reflect.DeepEqual(actual, expected) {
+			t.Error("\nexpected\n", expected, "\nactual\n", actual)
+		}
+	})
+
+	t.Run("group types", func(t *testing.T) {
+		groupTime := "1h"
+		derivative := "derivative-1s"
+		elapsed := "elapsed"
+		element := QueriesRequestElement{
+			Measurement: "m1",
+			Columns:     []QueriesRequestElementColumn{{Name: "c1", GroupType: &derivative}, {Name: "c2", GroupType: &elapsed}},
+		}
+		errs := element.Validate(0, PerQuery)
+		if len(errs) != 0 {
+			t.Error(errs)
+		}
+		element.OrderColumnIndex = nil
+		element.GroupTime = &groupTime
+		expected := ValidationErrors{
+			{Index: 0, Path: "[0].columns[0].groupType", Reason: "transformations require an aggregation if groupTime is set"},
+			{Index: 0, Path: "[0].columns[1].groupType", Reason: "transformations require an aggregation if groupTime is set"},
+		}
+		actual := element.Validate(0, PerQuery)
+		if !reflect.DeepEqual(actual, expected) {
+			t.Error("\nexpected\n", expected, "\nactual\n", actual)
+		}
+	})
+
+	t.Run("filter expression", func(t *testing.T) {
+		filter := QueriesRequestElementFilterExpression{Filter: &QueriesRequestElementFilter{Column: "c1", Type: "=", Value: 1.0}}
+		nested := filter
+		for i := 2; i < MaxFilterExpressionDepth; i++ {
+			inner := nested
+			nested = QueriesRequestElementFilterExpression{Not: &inner}
+		}
+		element := QueriesRequestElement{
+			Measurement:      "m1",
+			Columns:          []QueriesRequestElementColumn{{Name: "c1"}},
+			FilterExpression: &QueriesRequestElementFilterExpression{Or: []QueriesRequestElementFilterExpression{nested, filter}},
+		}
+		errs := element.Validate(0, PerQuery)
+		if len(errs) != 0 {
+			t.Error(errs)
+		}
+
+		element.OrderColumnIndex = nil
+		element.FilterExpression = &QueriesRequestElementFilterExpression{And: []QueriesRequestElementFilterExpression{
+			{Not: &nested},
+			{},
+			{Or: []QueriesRequestElementFilterExpression{}},
+			{Filter: &QueriesRequestElementFilter{Column: "c1", Type: "~", Value: 1.0}, TagFilter: &QueriesRequestElementTagFilter{}},
+			{Not: &QueriesRequestElementFilterExpression{TagFilter: &QueriesRequestElementTagFilter{Key: "t", Type: "~"}}},
+		}}
+		expected := ValidationErrors{
+			{Index: 0, Path: "[0].filterExpression.and[0].not.not.not.not.not.not.not", Reason: "must not be nested deeper than 8 levels"},
+			{Index: 0, Path: "[0].filterExpression.and[1]", Reason: "exactly one of and, or, not, filter, tagFilter has to be set"},
+			{Index: 0, Path: "[0].filterExpression.and[2].or", Reason: "must not be empty"},
+			{Index: 0, Path: "[0].filterExpression.and[3]", Reason: "exactly one of and, or, not, filter, tagFilter has to be set"},
+			{Index: 0, Path: "[0].filterExpression.and[4].not.tagFilter.type", Reason: "unknown tag filter type"},
+		}
+		actual := element.Validate(0, PerQuery)
+		if !reflect.DeepEqual(actual, expected) {
+			t.Error("\nexpected\n", expected, "\nactual\n", actual)
+		}
+
+		many := make([]QueriesRequestElementFilterExpression, MaxFilterExpressionNodes)
+		for i := range many {
+			many[i] = filter
+		}
+		element.FilterExpression = &QueriesRequestElementFilterExpression{Or: many}
+		expected = ValidationErrors{{Index: 0, Path: "[0].filterExpression", Reason: "must not contain more than 100 nodes"}}
+		actual = element.Validate(0, PerQuery)
+		if !reflect.DeepEqual(actual, expected) {
+			t.Error("\nexpected\n", expected, "\nactual\n", actual)
+		}
+	})
+
+	t.Run("fill", func(t *testing.T) {
+		groupTime := "1h"
+		for _, fill := range []interface{}{FillNull, FillNone, FillPrevious, FillLinear, 0.0, -2.5} {
+			element := QueriesRequestElement{Measurement: "m1", Columns: []QueriesRequestElementColumn{{Name: "c1"}}, GroupTime: &groupTime, Fill: fill}
+			errs := element.Validate(0, PerQuery)
+			if len(errs) != 0 {
+				t.Error(fill, errs)
+			}
+		}
+		element := QueriesRequestElement{Measurement: "m1", Columns: []QueriesRequestElementColumn{{Name: "c1"}}, Fill: "zero"}
+		expected := ValidationErrors{
+			{Index: 0, Path: "[0].fill", Reason: "requires groupTime"},
+			{Index: 0, Path: "[0].fill", Reason: "must be one of null, none, previous, linear or a number"},
+		}
+		actual := element.Validate(0, PerQuery)
+		if !reflect.DeepEqual(actual, expected) {
+			t.Error("\nexpected\n", expected, "\nactual\n", actual)
+		}
+	})
+
+	t.Run("pagination", func(t *testing.T) {
+		groupTime := "1h"
+		offset := -1
+		cursor := "x"
+		element := QueriesRequestElement{
+			Measurement: "m1",
+			Columns:     []QueriesRequestElementColumn{{Name: "c1"}},
+			GroupTime:   &groupTime,
+			Offset:      &offset,
+			Cursor:      &cursor,
+		}
+		expected := ValidationErrors{
+			{Index: 0, Path: "[0].offset", Reason: "must not be negative"},
+			{Index: 0, Path: "[0].offset", Reason: "can not be combined with groupTime"},
+			{Index: 0, Path: "[0].cursor", Reason: "invalid cursor"},
+			{Index: 0, Path: "[0].cursor", Reason: "can not be combined with groupTime"},
+			{Index: 0, Path: "[0].cursor", Reason: "can not be combined with offset"},
+		}
+		actual := element.Validate(0, PerQuery)
+		if !reflect.DeepEqual(actual, expected) {
+			t.Error("\nexpected\n", expected, "\nactual\n", actual)
+		}
+	})
+
+	t.Run("timezone", func(t *testing.T) {
+		for timezone, valid := range map[string]bool{"Europe/Berlin": true, "UTC": true, "Mars/Olympus": false, "Local": false} {
+			tz := timezone
+			element := QueriesRequestElement{Measurement: "m1", Columns: []QueriesRequestElementColumn{{Name: "c1"}}, Timezone: &tz}
+			errs := element.Validate(0, PerQuery)
+			if valid != (len(errs) == 0) {
+				t.Error(timezone, errs)
+			}
+		}
+	})
+
+	t.Run("time range", func(t *testing.T) {
+		end := "2021-01-01"
+		elementTime := QueriesRequestElementTime{End: &end}
+		expected := ValidationErrors{
+			{Index: 0, Path: "[0].time.start", Reason: "required if neither last nor ahead are set"},
+			{Index: 0, Path: "[0].time.end", Reason: "must be formatted as rfc3339"},
+		}
+		actual := elementTime.Validate(0, "[0].time")
+		if !reflect.DeepEqual(actual, expected) {
+			t.Error("\nexpected\n", expected, "\nactual\n", actual)
+		}
+	})
+}
@@ -0,0 +1,135 @@
+/*
+ *    Copyright 2021 InfAI (CC SES)
+ *
+ *    Licensed under the Apache License, Version 2.0 (the "License");
+ *    you may not use this file except in compliance with the License.
+ *    You may obtain a copy of the License at
+ *
+ *        http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *    Unless required by applicable law or agreed to in writing, software
+ *    distributed under the License is distributed on an "AS IS" BASIS,
+ *    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *    See the License for the specific language governing permissions and
+ *    limitations under the License.
+ */
+
+package model
+
+import (
+	"errors"
+	"math"
+	"strconv"
+	"time"
+)
+
+// InfiniteDuration is the duration of retention policies which keep data forever
+const InfiniteDuration = "INF"
+
+// AutogenRetentionPolicy is the default retention policy created by InfluxDB with each database, it can not be altered
+const AutogenRetentionPolicy = "autogen"
+
+// RetentionPolicy is a retention policy of the database of the user. Durations are formatted by InfluxDB, e.g.
+// 720h0m0s. An infinite duration is 0s.
+type RetentionPolicy struct {
+	Name               string `json:"name"`
+	Duration           string `json:"duration"`
+	ShardGroupDuration string `json:"shardGroupDuration"`
+	Default            bool   `json:"default"`
+}
+
+// RetentionPolicyLimits restricts the retention policies users may create. Zero values are not limited.
+type RetentionPolicyLimits struct {
+	MaxCount    int
+	MinDuration time.Duration
+	MaxDuration time.Duration
+}
+
+// RetentionPolicyRequest creates a retention policy or alters an existing one. Duration is a time interval like 30d or
+// INF. Fields which are not set are not altered. Default can only be set, a policy stops being the default if
+// another one is made the default.
+type RetentionPolicyRequest struct {
+	Name          string
+	Duration      *string
+	ShardDuration *string
+	Default       bool
+}
+
+// Validate checks the request to create a retention policy, or to alter one if create is false. The name of an
+// altered policy is taken from the path and may not be set in the request.
+func (request *RetentionPolicyRequest) Validate(create bool, limits RetentionPolicyLimits) (errs ValidationErrors) {
+	if create && len(request.Name) == 0 {
+		errs = errs.Add(0, "name", "must not be empty")
+	}
+	if !create && len(request.Name) > 0 {
+		errs = errs.Add(0, "name", "can not be changed")
+	}
+	if create && request.Duration == nil {
+		errs = errs.Add(0, "duration", "must not be empty")
+	}
+	if !create && request.Duration == nil && request.ShardDuration == nil && !request.Default {
+		errs = errs.Add(0, "duration", "one of duration, shardDuration or default is required")
+	}
+	var duration time.Duration
+	if request.Duration != nil {
+		var err error
+		duration, err = parseRetention(*request.Duration)
+		switch {
+		case err != nil:
+			errs = errs.Add(0, "duration", err.Error())
+		case duration == 0 && limits.MaxDuration > 0:
+			errs = errs.Add(0, "duration", "must not be infinite, the maximum is "+limits.MaxDuration.String())
+		case duration != 0 && duration < limits.MinDuration:
+			errs = errs.Add(0, "duration", "must be at least "+limits.MinDuration.String())
+		case limits.MaxDuration > 0 && duration > limits.MaxDuration:
+			errs = errs.Add(0, "duration", "must not exceed "+limits.MaxDuration.String())
+		}
+	}
+	if request.ShardDuration != nil {
+		shardDuration, err := ParseTimeInterval(*request.ShardDuration)
+		if err != nil {
+			errs = errs.Add(0, "shardDuration", err.Error())
+		} else if duration > 0 && shardDuration > duration {
+			errs = errs.Add(0, "shardDuration", "must not exceed duration")
+		}
+	}
+	return errs
+}
+
+// parseRetention parses a time interval or INF, which is returned as 0
+func parseRetention(interval string) (time.Duration, error) {
+	if interval == InfiniteDuration {
+		return 0, nil
+	}
+	duration, err := ParseTimeInterval(interval)
+	if err == nil && duration == 0 {
+		return 0, errors.New("must be greater than 0, use " + InfiniteDuration + " to keep data forever")
+	}
+	return duration, err
+}
+
+var timeIntervalUnits = map[string]time.Duration{
+	"ns": time.Nanosecond,
+	"u":  time.Microsecond,
+	"µ":  time.Microsecond,
+	"ms": time.Millisecond,
+	"s":  time.Second,
+	"m":  time.Minute,
+	"h":  time.Hour,
+	"d":  24 * time.Hour,
+	"w":  7 * 24 * time.Hour,
+}
+
+// ParseTimeInterval parses a time interval with a single unit as used by InfluxQL, e.g. 30d
+func ParseTimeInterval(interval string) (time.Duration, error) {
+	if !timeIntervalValid(interval) {
+		return 0, errors.New("invalid time interval")
+	}
+	match := timeIntervalMatcher.FindStringSubmatch(interval)
+	value, err := strconv.ParseInt(match[1], 10, 64)
+	unit := timeIntervalUnits[match[2]]
+	if err != nil || value > math.MaxInt64/int64(unit) {
+		return 0, errors.New("time interval too large")
+	}
+	return time.Duration(value) * unit, nil
+}
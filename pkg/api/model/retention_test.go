@@ -0,0 +1,83 @@
+/*
+ *    Copyright 2021 InfAI (CC SES)
+ *
+ *    Licensed under the Apache License, Version 2.0 (the "License");
+ *    you may not use this file except in compliance with the License.
+ *    You may obtain a copy of the License at
+ *
+ *        http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *    Unless required by applicable law or agreed to in writing, software
+ *    distributed under the License is distributed on an "AS IS" BASIS,
+ *    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *    See the License for the specific language governing permissions and
+ *    limitations under the License.
+ */
+
+package model
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseTimeInterval(t *testing.T) {
+	valid := map[string]time.Duration{
+		"0s":   0,
+		"90m":  90 * time.Minute,
+		"30d":  30 * 24 * time.Hour,
+		"2w":   14 * 24 * time.Hour,
+		"10ms": 10 * time.Millisecond,
+	}
+	for interval, expect := range valid {
+		actual, err := ParseTimeInterval(interval)
+		if err != nil || actual != expect || !timeIntervalValid(interval) {
+			t.Error(interval, actual, err)
+		}
+	}
+	for _, interval := range []string{"", "1", "d", "1h30m", "-1h", "1 d"} {
+		if _, err := ParseTimeInterval(interval); err == nil || timeIntervalValid(interval) {
+			t.Error("expected error for", interval)
+		}
+	}
+	// valid, but not representable as duration
+	if _, err := ParseTimeInterval("99999999999w"); err == nil {
+		t.Error("expected error for too large interval")
+	}
+}
+
+func TestRetentionPolicyRequestValidate(t *testing.T) {
+	limits := RetentionPolicyLimits{MinDuration: time.Hour, MaxDuration: 365 * 24 * time.Hour}
+	day := "1d"
+	week := "1w"
+	year := "52w"
+	twoYears := "104w"
+	minute := "30m"
+	zero := "0s"
+	inf := InfiniteDuration
+	cases := map[string]struct {
+		request RetentionPolicyRequest
+		create  bool
+		limits  RetentionPolicyLimits
+		errors  int
+	}{
+		"create":                  {RetentionPolicyRequest{Name: "raw", Duration: &year, ShardDuration: &week}, true, limits, 0},
+		"create without name":     {RetentionPolicyRequest{Duration: &year}, true, limits, 1},
+		"create without duration": {RetentionPolicyRequest{Name: "raw"}, true, limits, 1},
+		"too long":                {RetentionPolicyRequest{Name: "raw", Duration: &twoYears}, true, limits, 1},
+		"too short":               {RetentionPolicyRequest{Name: "raw", Duration: &minute}, true, limits, 1},
+		"zero":                    {RetentionPolicyRequest{Name: "raw", Duration: &zero}, true, limits, 1},
+		"infinite":                {RetentionPolicyRequest{Name: "raw", Duration: &inf}, true, limits, 1},
+		"infinite unlimited":      {RetentionPolicyRequest{Name: "raw", Duration: &inf}, true, RetentionPolicyLimits{}, 0},
+		"shard too long":          {RetentionPolicyRequest{Name: "raw", Duration: &day, ShardDuration: &week}, true, limits, 1},
+		"alter":                   {RetentionPolicyRequest{Default: true}, false, limits, 0},
+		"alter name":              {RetentionPolicyRequest{Name: "raw", Duration: &day}, false, limits, 1},
+		"alter nothing":           {RetentionPolicyRequest{}, false, limits, 1},
+	}
+	for name, c := range cases {
+		errs := c.request.Validate(c.create, c.limits)
+		if len(errs) != c.errors {
+			t.Error(name, errs)
+		}
+	}
+}
@@ -0,0 +1,40 @@
+/*
+ *    Copyright 2021 InfAI (CC SES)
+ *
+ *    Licensed under the Apache License, Version 2.0 (the "License");
+ *    you may not use this file except in compliance with the License.
+ *    You may obtain a copy of the License at
+ *
+ *        http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *    Unless required by applicable law or agreed to in writing, software
+ *    distributed under the License is distributed on an "AS IS" BASIS,
+ *    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *    See the License for the specific language governing permissions and
+ *    limitations under the License.
+ */
+
+package model
+
+// FieldKey is a field of a measurement with its InfluxDB type: float, integer, string, boolean or unsigned
+type FieldKey struct {
+	Key  string `json:"key"`
+	Type string `json:"type"`
+}
+
+// MeasurementsPage is a page of a schema listing. NextOffset is the offset of the next page, nil if there are no more
+// items, as in all other pages.
+type MeasurementsPage struct {
+	Measurements []string `json:"measurements"`
+	NextOffset   *int     `json:"nextOffset,omitempty"`
+}
+
+type FieldKeysPage struct {
+	FieldKeys  []FieldKey `json:"fieldKeys"`
+	NextOffset *int       `json:"nextOffset,omitempty"`
+}
+
+type TagKeysPage struct {
+	TagKeys    []string `json:"tagKeys"`
+	NextOffset *int     `json:"nextOffset,omitempty"`
+}
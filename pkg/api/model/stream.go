@@ -0,0 +1,27 @@
+/*
+ *    Copyright 2021 InfAI (CC SES)
+ *
+ *    Licensed under the Apache License, Version 2.0 (the "License");
+ *    you may not use this file except in compliance with the License.
+ *    You may obtain a copy of the License at
+ *
+ *        http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *    Unless required by applicable law or agreed to in writing, software
+ *    distributed under the License is distributed on an "AS IS" BASIS,
+ *    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *    See the License for the specific language governing permissions and
+ *    limitations under the License.
+ */
+
+package model
+
+// StreamRow is a single line of a ndjson response. Element is the index of the request element the row belongs to.
+// If streaming fails after the first row has been sent, a final line with Error set is written. If an element might
+// have more data, a line with the Continuation token is written after its last row.
+type StreamRow struct {
+	Element      int           `json:"element"`
+	Row          []interface{} `json:"row,omitempty"`
+	Error        string        `json:"error,omitempty"`
+	Continuation string        `json:"continuation,omitempty"`
+}
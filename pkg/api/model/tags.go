@@ -0,0 +1,52 @@
+/*
+ *    Copyright 2021 InfAI (CC SES)
+ *
+ *    Licensed under the Apache License, Version 2.0 (the "License");
+ *    you may not use this file except in compliance with the License.
+ *    You may obtain a copy of the License at
+ *
+ *        http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *    Unless required by applicable law or agreed to in writing, software
+ *    distributed under the License is distributed on an "AS IS" BASIS,
+ *    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *    See the License for the specific language governing permissions and
+ *    limitations under the License.
+ */
+
+package model
+
+// TagValue is a single value of a tag key
+type TagValue struct {
+	Key   string
+	Value string
+}
+
+// TagsPage is a page of tag values grouped by key. Counts holds the number of matching values of each key across all
+// pages. NextOffset is the offset of the next page, nil if there are no more values.
+type TagsPage struct {
+	Tags       map[string][]string `json:"tags"`
+	Counts     map[string]int      `json:"counts"`
+	NextOffset *int                `json:"nextOffset,omitempty"`
+}
+
+// NewTagsPage groups the values of a page, which starts at offset and holds up to limit values. counts holds the
+// number of values of each key across all pages.
+func NewTagsPage(values []TagValue, counts map[string]int, limit int, offset int) TagsPage {
+	page := TagsPage{
+		Tags:   map[string][]string{},
+		Counts: counts,
+	}
+	total := 0
+	for _, count := range counts {
+		total += count
+	}
+	for _, value := range values {
+		page.Tags[value.Key] = append(page.Tags[value.Key], value.Value)
+	}
+	if total > offset+limit {
+		next := offset + limit
+		page.NextOffset = &next
+	}
+	return page
+}
@@ -0,0 +1,53 @@
+/*
+ *    Copyright 2021 InfAI (CC SES)
+ *
+ *    Licensed under the Apache License, Version 2.0 (the "License");
+ *    you may not use this file except in compliance with the License.
+ *    You may obtain a copy of the License at
+ *
+ *        http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *    Unless required by applicable law or agreed to in writing, software
+ *    distributed under the License is distributed on an "AS IS" BASIS,
+ *    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *    See the License for the specific language governing permissions and
+ *    limitations under the License.
+ */
+
+package model
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestNewTagsPage(t *testing.T) {
+	counts := map[string]int{"device": 3, "service": 1, "unknown": 0}
+	t.Run("first page", func(t *testing.T) {
+		page := NewTagsPage([]TagValue{{Key: "device", Value: "d1"}, {Key: "device", Value: "d2"}}, counts, 2, 0)
+		if !reflect.DeepEqual(page.Tags, map[string][]string{"device": {"d1", "d2"}}) {
+			t.Error(page.Tags)
+		}
+		if !reflect.DeepEqual(page.Counts, counts) {
+			t.Error(page.Counts)
+		}
+		if page.NextOffset == nil || *page.NextOffset != 2 {
+			t.Error(page.NextOffset)
+		}
+	})
+	t.Run("last page", func(t *testing.T) {
+		page := NewTagsPage([]TagValue{{Key: "device", Value: "d3"}, {Key: "service", Value: "s1"}}, counts, 2, 2)
+		if !reflect.DeepEqual(page.Tags, map[string][]string{"device": {"d3"}, "service": {"s1"}}) {
+			t.Error(page.Tags)
+		}
+		if page.NextOffset != nil {
+			t.Error(*page.NextOffset)
+		}
+	})
+	t.Run("beyond end", func(t *testing.T) {
+		page := NewTagsPage([]TagValue{}, counts, 2, 10)
+		if len(page.Tags) != 0 || page.Counts["device"] != 3 || page.NextOffset != nil {
+			t.Error(page)
+		}
+	})
+}
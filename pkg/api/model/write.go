@@ -0,0 +1,42 @@
+/*
+ *    Copyright 2021 InfAI (CC SES)
+ *
+ *    Licensed under the Apache License, Version 2.0 (the "License");
+ *    you may not use this file except in compliance with the License.
+ *    You may obtain a copy of the License at
+ *
+ *        http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *    Unless required by applicable law or agreed to in writing, software
+ *    distributed under the License is distributed on an "AS IS" BASIS,
+ *    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *    See the License for the specific language governing permissions and
+ *    limitations under the License.
+ */
+
+package model
+
+// WritePoint is a point of a JSON body of /write. Field values may be numbers, strings or booleans, numbers are
+// written as float unless the field already exists as integer. Time is formatted as rfc3339, the time of the request is
+// used if it is missing.
+type WritePoint struct {
+	Measurement string
+	Tags        map[string]string
+	Fields      map[string]interface{}
+	Time        *string
+}
+
+// WriteResponse reports the result of /write. Errors holds an entry for each point that was not written.
+type WriteResponse struct {
+	Written int               `json:"written"`
+	Failed  int               `json:"failed"`
+	Errors  []WritePointError `json:"errors,omitempty"`
+}
+
+// WritePointError describes why a point was not written. Index is the index of the point in the request, Line is the
+// line number of points written as line protocol.
+type WritePointError struct {
+	Index int    `json:"index"`
+	Line  int    `json:"line,omitempty"`
+	Error string `json:"error"`
+}
@@ -0,0 +1,131 @@
+/*
+ *    Copyright 2021 InfAI (CC SES)
+ *
+ *    Licensed under the Apache License, Version 2.0 (the "License");
+ *    you may not use this file except in compliance with the License.
+ *    You may obtain a copy of the License at
+ *
+ *        http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *    Unless required by applicable law or agreed to in writing, software
+ *    distributed under the License is distributed on an "AS IS" BASIS,
+ *    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *    See the License for the specific language governing permissions and
+ *    limitations under the License.
+ */
+
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/SENERGY-Platform/influx-wrapper/pkg/api/model"
+	"github.com/SENERGY-Platform/influx-wrapper/pkg/configuration"
+	influxdb "github.com/SENERGY-Platform/influx-wrapper/pkg/influx"
+	"github.com/SENERGY-Platform/influx-wrapper/pkg/metrics"
+	"github.com/orourkedd/influxdb1-client/models"
+	"net/http"
+	"time"
+)
+
+const ndjsonContentType = "application/x-ndjson"
+
+// streams the results of all request elements as ndjson. Statements are executed one after another as chunked queries,
+// each chunk is written and flushed before the next one is read.
+func streamQueries(writer http.ResponseWriter, request *http.Request, config configuration.Config, influx *influxdb.Influx,
+	db string, queriesRequest queriesRequest) {
+	statements, err := influxdb.BuildQueries(queriesRequest.elements, queriesRequest.timeDirection())
+	if err != nil {
+		http.Error(writer, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	ctx, cancel := streamContext(config, request)
+	defer cancel()
+	controller, err := streamController(ctx, writer)
+	if err != nil {
+		http.Error(writer, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	encoder := json.NewEncoder(writer)
+	started := false
+	rows := 0
+	for index := range statements {
+		tracker := newPageTracker(queriesRequest.elements[index], queriesRequest.timeDirection())
+		query, err := statements[index].Render()
+		if err == nil {
+			err = influx.StreamQuery(ctx, db, query, func(series models.Row) error {
+				if !started {
+					writer.Header().Set("Content-Type", ndjsonContentType)
+					started = true
+				}
+				if err := tracker.add(series); err != nil {
+					return err
+				}
+				for _, row := range series.Values {
+					if len(row) > 0 {
+						formatted, err := queriesRequest.timeFormat.formatString(row[0], queriesRequest.locations[index])
+						if err != nil {
+							return err
+						}
+						row[0] = formatted
+					}
+					for _, tag := range queriesRequest.elements[index].GroupTags {
+						row = append(row, series.Tags[tag])
+					}
+					err := encoder.Encode(model.StreamRow{Element: index, Row: row})
+					if err != nil {
+						return err
+					}
+				}
+				rows += len(series.Values)
+				err := controller.Flush()
+				if err != nil && !errors.Is(err, http.ErrNotSupported) {
+					return err
+				}
+				return nil
+			})
+		}
+		if err != nil {
+			if !started {
+				writeInfluxError(writer, err)
+				return
+			}
+			err = encoder.Encode(model.StreamRow{Element: index, Error: err.Error()})
+			if err != nil {
+				fmt.Println("ERROR: " + err.Error())
+			}
+			return
+		}
+		if token := tracker.token(); token != nil {
+			err = encoder.Encode(model.StreamRow{Element: index, Continuation: *token})
+			if err != nil {
+				fmt.Println("ERROR: " + err.Error())
+				return
+			}
+		}
+	}
+	if !started {
+		writer.Header().Set("Content-Type", ndjsonContentType)
+		writer.WriteHeader(http.StatusOK)
+	}
+	metrics.ObserveResponseRows("/queries", rows)
+}
+
+// streamController extends the write deadline of the server to the deadline of ctx, so streamed responses are only
+// limited by the stream timeout
+func streamController(ctx context.Context, writer http.ResponseWriter) (*http.ResponseController, error) {
+	controller := http.NewResponseController(writer)
+	deadline, ok := ctx.Deadline()
+	if !ok {
+		deadline = time.Time{}
+	}
+	err := controller.SetWriteDeadline(deadline)
+	if err != nil && !errors.Is(err, http.ErrNotSupported) {
+		return nil, err
+	}
+	return controller, nil
+}
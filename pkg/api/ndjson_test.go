@@ -0,0 +1,149 @@
+/*
+ *    Copyright 2021 InfAI (CC SES)
+ *
+ *    Licensed under the Apache License, Version 2.0 (the "License");
+ *    you may not use this file except in compliance with the License.
+ *    You may obtain a copy of the License at
+ *
+ *        http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *    Unless required by applicable law or agreed to in writing, software
+ *    distributed under the License is distributed on an "AS IS" BASIS,
+ *    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *    See the License for the specific language governing permissions and
+ *    limitations under the License.
+ */
+
+package api
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"github.com/SENERGY-Platform/influx-wrapper/pkg/api/model"
+	"github.com/SENERGY-Platform/influx-wrapper/pkg/configuration"
+	influxdb "github.com/SENERGY-Platform/influx-wrapper/pkg/influx"
+	"github.com/SENERGY-Platform/influx-wrapper/pkg/tests/services"
+	"github.com/julienschmidt/httprouter"
+	influxLib "github.com/orourkedd/influxdb1-client"
+	"github.com/orourkedd/influxdb1-client/models"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// deadlineRecorder records the write deadlines and flushes requested through http.ResponseController
+type deadlineRecorder struct {
+	*httptest.ResponseRecorder
+	deadlines []time.Time
+	flushes   int
+}
+
+func (this *deadlineRecorder) SetWriteDeadline(deadline time.Time) error {
+	this.deadlines = append(this.deadlines, deadline)
+	return nil
+}
+
+func (this *deadlineRecorder) FlushError() error {
+	this.flushes++
+	this.ResponseRecorder.Flush()
+	return nil
+}
+
+func TestQueriesNDJSON(t *testing.T) {
+	influxClientMock := services.NewClientMock()
+	config := &configuration.ConfigStruct{StreamTimeout: "10m"}
+	router := httprouter.New()
+	QueriesEndpoint(router, config, influxdb.NewInfluxWithClient(config, &influxClientMock))
+	columns := []string{"time", "c1"}
+	chunk := func(values ...[]interface{}) *influxLib.Response {
+		return &influxLib.Response{Results: []influxLib.Result{{Series: []models.Row{{Name: "m1", Columns: columns, Values: values}}}}}
+	}
+
+	query := func(t *testing.T, body string) (*deadlineRecorder, []model.StreamRow) {
+		request := httptest.NewRequest("POST", "/queries?format=ndjson", strings.NewReader(body))
+		request.Header.Set(userHeader, "db")
+		writer := &deadlineRecorder{ResponseRecorder: httptest.NewRecorder()}
+		router.ServeHTTP(writer, request)
+		lines := []model.StreamRow{}
+		if writer.Header().Get("Content-Type") != ndjsonContentType {
+			return writer, lines
+		}
+		scanner := bufio.NewScanner(writer.Body)
+		for scanner.Scan() {
+			line := model.StreamRow{}
+			err := json.Unmarshal(scanner.Bytes(), &line)
+			if err != nil {
+				t.Error(scanner.Text(), err)
+			}
+			lines = append(lines, line)
+		}
+		return writer, lines
+	}
+
+	t.Run("line per row", func(t *testing.T) {
+		influxClientMock.SetChunkedResponse([]*influxLib.Response{
+			chunk([]interface{}{"2021-01-01T00:00:02Z", 2.0}, []interface{}{"2021-01-01T00:00:01Z", 1.0}),
+			chunk([]interface{}{"2021-01-01T00:00:00Z", 0.0}),
+		}, nil)
+		start := time.Now()
+		writer, lines := query(t, `[{"measurement":"m1","columns":[{"name":"c1"}]}]`)
+		if writer.Code != 200 || writer.Header().Get("Content-Type") != ndjsonContentType {
+			t.Error(writer.Code, writer.Header())
+		}
+		if len(lines) != 3 {
+			t.Error(lines)
+			return
+		}
+		for i, line := range lines {
+			if line.Element != 0 || len(line.Row) != 2 || line.Row[1] != float64(2-i) || line.Error != "" || line.Continuation != "" {
+				t.Error(i, line)
+			}
+		}
+		// one flush per chunk
+		if writer.flushes != 2 {
+			t.Error("expected 2 flushes, got", writer.flushes)
+		}
+		// the write deadline is extended to the stream timeout
+		if len(writer.deadlines) != 1 || writer.deadlines[0].Before(start.Add(10*time.Minute)) || writer.deadlines[0].After(time.Now().Add(10*time.Minute)) {
+			t.Error(writer.deadlines)
+		}
+	})
+	t.Run("continuation", func(t *testing.T) {
+		influxClientMock.SetChunkedResponse([]*influxLib.Response{
+			chunk([]interface{}{"2021-01-01T00:00:02Z", 2.0}, []interface{}{"2021-01-01T00:00:01Z", 1.0}),
+		}, nil)
+		_, lines := query(t, `[{"measurement":"m1","columns":[{"name":"c1"}],"limit":2}]`)
+		if len(lines) != 3 || lines[2].Continuation == "" || lines[2].Row != nil {
+			t.Error(lines)
+			return
+		}
+		cursor, err := model.ParseCursor(lines[2].Continuation)
+		if err != nil || !cursor.Time.Equal(time.Date(2021, 1, 1, 0, 0, 1, 0, time.UTC)) || cursor.Direction != model.Desc {
+			t.Error(cursor, err)
+		}
+	})
+	t.Run("error after first row", func(t *testing.T) {
+		influxClientMock.SetChunkedResponse([]*influxLib.Response{
+			chunk([]interface{}{"2021-01-01T00:00:02Z", 2.0}),
+			{Results: []influxLib.Result{{Err: errors.New("query interrupted")}}},
+		}, nil)
+		writer, lines := query(t, `[{"measurement":"m1","columns":[{"name":"c1"}]},{"measurement":"m2","columns":[{"name":"c1"}]}]`)
+		if writer.Code != 200 {
+			t.Error(writer.Code)
+		}
+		// the stream ends with the error, the second element is not queried
+		if len(lines) != 2 || lines[0].Row == nil || lines[1].Element != 0 || lines[1].Error != "query interrupted" {
+			t.Error(lines)
+		}
+	})
+	t.Run("error before first row", func(t *testing.T) {
+		influxClientMock.SetChunkedResponse(nil, errors.New("unexpected error"))
+		defer influxClientMock.SetChunkedResponse(nil, nil)
+		writer, _ := query(t, `[{"measurement":"m1","columns":[{"name":"c1"}]}]`)
+		if writer.Code != 500 || writer.Header().Get("Content-Type") == ndjsonContentType {
+			t.Error(writer.Code, writer.Header())
+		}
+	})
+}
@@ -0,0 +1,213 @@
+/*
+ *    Copyright 2021 InfAI (CC SES)
+ *
+ *    Licensed under the Apache License, Version 2.0 (the "License");
+ *    you may not use this file except in compliance with the License.
+ *    You may obtain a copy of the License at
+ *
+ *        http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *    Unless required by applicable law or agreed to in writing, software
+ *    distributed under the License is distributed on an "AS IS" BASIS,
+ *    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *    See the License for the specific language governing permissions and
+ *    limitations under the License.
+ */
+
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"github.com/SENERGY-Platform/influx-wrapper/pkg/api/model"
+	influxLib "github.com/orourkedd/influxdb1-client"
+	"github.com/orourkedd/influxdb1-client/models"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	defaultPageLimit = 100
+	maxPageLimit     = 10000
+)
+
+// parsePage reads the query params limit and offset of paginated listings
+func parsePage(query url.Values) (limit int, offset int, err error) {
+	limit = defaultPageLimit
+	if query.Has("limit") {
+		limit, err = strconv.Atoi(query.Get("limit"))
+		if err != nil || limit < 1 || limit > maxPageLimit {
+			return 0, 0, errors.New("Invalid param limit, must be between 1 and " + strconv.Itoa(maxPageLimit))
+		}
+	}
+	if query.Has("offset") {
+		offset, err = strconv.Atoi(query.Get("offset"))
+		if err != nil || offset < 0 {
+			return 0, 0, errors.New("Invalid param offset, must not be negative")
+		}
+	}
+	return limit, offset, nil
+}
+
+// nextOffset returns the offset of the next page if more than limit items were found. Listings request limit+1 items
+// to detect if there is a next page.
+func nextOffset(limit int, offset int, found int) *int {
+	if found <= limit {
+		return nil
+	}
+	next := offset + limit
+	return &next
+}
+
+// header with a JSON array holding the continuation token of each request element, or null if there is no more data
+const continuationHeader = "X-Continuation-Tokens"
+
+// pageTracker follows the series of a limited raw query. If a series returned as many rows as the limit allows,
+// there might be more data and a continuation token is created.
+type pageTracker struct {
+	limit     int
+	direction model.Direction
+	rows      map[string]int
+	last      map[string]time.Time
+	// number of rows at the end of each series sharing the last timestamp
+	lastRows map[string]int
+	// cursor of the request, nil on the first page
+	cursor *model.Cursor
+}
+
+// returns nil if element can not be paginated
+func newPageTracker(element model.QueriesRequestElement, direction model.Direction) *pageTracker {
+	if element.Limit == nil || element.GroupTime != nil {
+		return nil
+	}
+	tracker := &pageTracker{
+		limit:     *element.Limit,
+		direction: direction,
+		rows:      map[string]int{},
+		last:      map[string]time.Time{},
+		lastRows:  map[string]int{},
+	}
+	if element.Cursor != nil {
+		// already validated
+		cursor, _ := model.ParseCursor(*element.Cursor)
+		tracker.cursor = &cursor
+	}
+	return tracker
+}
+
+// add has to be called with the rows of a series in the order returned by influx. Rows of a series may be split
+// across multiple calls.
+func (this *pageTracker) add(series models.Row) error {
+	if this == nil || len(series.Values) == 0 {
+		return nil
+	}
+	key := seriesKey(series)
+	this.rows[key] += len(series.Values)
+	for _, row := range series.Values {
+		if len(row) == 0 {
+			continue
+		}
+		t, err := rowTime(row[0])
+		if err != nil {
+			return err
+		}
+		last, ok := this.last[key]
+		if ok && last.Equal(t) {
+			this.lastRows[key]++
+			continue
+		}
+		this.last[key] = t
+		this.lastRows[key] = 1
+	}
+	return nil
+}
+
+func rowTime(value interface{}) (time.Time, error) {
+	switch t := value.(type) {
+	case time.Time:
+		return t, nil
+	case string:
+		return time.Parse(time.RFC3339, t)
+	default:
+		return time.Time{}, errors.New("unexpected time value")
+	}
+}
+
+// token returns nil if all series are complete. Otherwise the token continues at the last time of the least advanced
+// complete series and skips the rows of this time it already returned, so rows of series grouped by tags may be
+// repeated, but are never skipped.
+func (this *pageTracker) token() *string {
+	if this == nil {
+		return nil
+	}
+	var cursor *model.Cursor
+	for key, rows := range this.rows {
+		last, ok := this.last[key]
+		if rows < this.limit || !ok {
+			continue
+		}
+		skip := this.lastRows[key]
+		if this.cursor != nil && this.cursor.Skip > 0 && last.Equal(this.cursor.Time) {
+			// all rows of the page share the time of the request cursor, so the rows skipped before are skipped again
+			skip += this.cursor.Skip
+		}
+		if cursor == nil ||
+			(this.direction == model.Desc && last.After(cursor.Time)) ||
+			(this.direction == model.Asc && last.Before(cursor.Time)) {
+			cursor = &model.Cursor{Time: last, Direction: this.direction, Skip: skip}
+		} else if last.Equal(cursor.Time) && skip < cursor.Skip {
+			cursor.Skip = skip
+		}
+	}
+	if cursor == nil {
+		return nil
+	}
+	token := cursor.Token()
+	return &token
+}
+
+func seriesKey(series models.Row) string {
+	keys := make([]string, 0, len(series.Tags))
+	for key := range series.Tags {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	b := strings.Builder{}
+	for _, key := range keys {
+		b.WriteString(key + "=" + series.Tags[key] + ",")
+	}
+	return b.String()
+}
+
+// sets the continuation header if there is a token for at least one element. Results are expected to be unformatted.
+func setContinuationTokens(writer http.ResponseWriter, queriesRequest queriesRequest, results []influxLib.Result) error {
+	tokens := make([]*string, len(queriesRequest.elements))
+	found := false
+	for i, result := range results {
+		if i >= len(tokens) {
+			break
+		}
+		tracker := newPageTracker(queriesRequest.elements[i], queriesRequest.timeDirection())
+		for _, series := range result.Series {
+			err := tracker.add(series)
+			if err != nil {
+				return err
+			}
+		}
+		tokens[i] = tracker.token()
+		found = found || tokens[i] != nil
+	}
+	if !found {
+		return nil
+	}
+	b, err := json.Marshal(tokens)
+	if err != nil {
+		return err
+	}
+	writer.Header().Set(continuationHeader, string(b))
+	return nil
+}
@@ -0,0 +1,190 @@
+/*
+ *    Copyright 2021 InfAI (CC SES)
+ *
+ *    Licensed under the Apache License, Version 2.0 (the "License");
+ *    you may not use this file except in compliance with the License.
+ *    You may obtain a copy of the License at
+ *
+ *        http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *    Unless required by applicable law or agreed to in writing, software
+ *    distributed under the License is distributed on an "AS IS" BASIS,
+ *    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *    See the License for the specific language governing permissions and
+ *    limitations under the License.
+ */
+
+package api
+
+import (
+	"encoding/json"
+	"github.com/SENERGY-Platform/influx-wrapper/pkg/api/model"
+	influxLib "github.com/orourkedd/influxdb1-client"
+	"github.com/orourkedd/influxdb1-client/models"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestPageTracker(t *testing.T) {
+	limit := 3
+	element := model.QueriesRequestElement{Limit: &limit}
+	t1 := "2021-01-01T00:00:01Z"
+	t2 := "2021-01-01T00:00:02Z"
+	t3 := "2021-01-01T00:00:03Z"
+
+	parseToken := func(t *testing.T, token *string) model.Cursor {
+		if token == nil {
+			t.Fatal("expected token")
+		}
+		cursor, err := model.ParseCursor(*token)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return cursor
+	}
+
+	t.Run("incomplete", func(t *testing.T) {
+		tracker := newPageTracker(element, model.Desc)
+		err := tracker.add(models.Row{Values: [][]interface{}{{t3, 1}, {t2, 2}}})
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		if token := tracker.token(); token != nil {
+			t.Error("unexpected token", *token)
+		}
+	})
+	t.Run("not paginated", func(t *testing.T) {
+		groupTime := "1h"
+		if newPageTracker(model.QueriesRequestElement{}, model.Desc) != nil ||
+			newPageTracker(model.QueriesRequestElement{Limit: &limit, GroupTime: &groupTime}, model.Desc) != nil {
+			t.Error("expected nil tracker")
+		}
+		var tracker *pageTracker
+		if tracker.add(models.Row{Values: [][]interface{}{{t1, 1}}}) != nil || tracker.token() != nil {
+			t.Error("nil tracker has to be usable")
+		}
+	})
+	t.Run("complete", func(t *testing.T) {
+		tracker := newPageTracker(element, model.Desc)
+		err := tracker.add(models.Row{Values: [][]interface{}{{t3, 1}, {t2, 2}, {t1, 3}}})
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		cursor := parseToken(t, tracker.token())
+		if !cursor.Time.Equal(time.Date(2021, 1, 1, 0, 0, 1, 0, time.UTC)) || cursor.Direction != model.Desc || cursor.Skip != 1 {
+			t.Error(cursor)
+		}
+	})
+	t.Run("page within equal timestamps", func(t *testing.T) {
+		// the previous page ended with 2 rows at t2, this page returns 3 more rows at t2
+		token := model.Cursor{Time: time.Date(2021, 1, 1, 0, 0, 2, 0, time.UTC), Direction: model.Asc, Skip: 2}.Token()
+		tracker := newPageTracker(model.QueriesRequestElement{Limit: &limit, Cursor: &token}, model.Asc)
+		err := tracker.add(models.Row{Values: [][]interface{}{{t2, 3}, {t2, 4}, {t2, 5}}})
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		cursor := parseToken(t, tracker.token())
+		if !cursor.Time.Equal(time.Date(2021, 1, 1, 0, 0, 2, 0, time.UTC)) || cursor.Skip != 5 {
+			t.Error(cursor)
+		}
+	})
+	t.Run("page boundary within equal timestamps", func(t *testing.T) {
+		// rows of multiple series merged into a single result series share timestamps
+		tracker := newPageTracker(element, model.Asc)
+		err := tracker.add(models.Row{Values: [][]interface{}{{t1, 1}, {t2, 2}}})
+		if err == nil {
+			err = tracker.add(models.Row{Values: [][]interface{}{{t2, 3}}})
+		}
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		cursor := parseToken(t, tracker.token())
+		if !cursor.Time.Equal(time.Date(2021, 1, 1, 0, 0, 2, 0, time.UTC)) || cursor.Direction != model.Asc || cursor.Skip != 2 {
+			t.Error(cursor)
+		}
+	})
+	t.Run("least advanced series", func(t *testing.T) {
+		tracker := newPageTracker(element, model.Desc)
+		for _, series := range []models.Row{
+			{Tags: map[string]string{"device": "d1"}, Values: [][]interface{}{{t3, 1}, {t2, 2}, {t1, 3}}},
+			{Tags: map[string]string{"device": "d2"}, Values: [][]interface{}{{t3, 1}, {t3, 2}, {t2, 3}}},
+			{Tags: map[string]string{"device": "d3"}, Values: [][]interface{}{{t3, 1}}},
+		} {
+			if err := tracker.add(series); err != nil {
+				t.Error(err)
+				return
+			}
+		}
+		cursor := parseToken(t, tracker.token())
+		if !cursor.Time.Equal(time.Date(2021, 1, 1, 0, 0, 2, 0, time.UTC)) || cursor.Skip != 1 {
+			t.Error(cursor)
+		}
+	})
+	t.Run("invalid time", func(t *testing.T) {
+		tracker := newPageTracker(element, model.Desc)
+		if tracker.add(models.Row{Values: [][]interface{}{{"yesterday", 1}}}) == nil {
+			t.Error("expected error")
+		}
+	})
+}
+
+func TestSetContinuationTokens(t *testing.T) {
+	limit := 2
+	request := queriesRequest{
+		elements: []model.QueriesRequestElement{
+			{Measurement: "m1", Limit: &limit},
+			{Measurement: "m2", Limit: &limit},
+			{Measurement: "m3"},
+		},
+		orderDirection: model.Desc,
+	}
+	results := []influxLib.Result{
+		{Series: []models.Row{{Name: "m1", Columns: []string{"time", "c1"}, Values: [][]interface{}{{"2021-01-01T00:00:02Z", 1}, {"2021-01-01T00:00:02Z", 2}}}}},
+		{Series: []models.Row{{Name: "m2", Columns: []string{"time", "c1"}, Values: [][]interface{}{{"2021-01-01T00:00:02Z", 1}}}}},
+		{Series: []models.Row{{Name: "m3", Columns: []string{"time", "c1"}, Values: [][]interface{}{{"2021-01-01T00:00:02Z", 1}, {"2021-01-01T00:00:01Z", 2}}}}},
+	}
+
+	t.Run("header", func(t *testing.T) {
+		writer := httptest.NewRecorder()
+		err := setContinuationTokens(writer, request, results)
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		tokens := []*string{}
+		err = json.Unmarshal([]byte(writer.Header().Get(continuationHeader)), &tokens)
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		if len(tokens) != 3 || tokens[0] == nil || tokens[1] != nil || tokens[2] != nil {
+			t.Error(tokens)
+			return
+		}
+		cursor, err := model.ParseCursor(*tokens[0])
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		// both rows share the boundary timestamp, so the next page has to skip both of them
+		if !cursor.Time.Equal(time.Date(2021, 1, 1, 0, 0, 2, 0, time.UTC)) || cursor.Skip != 2 || cursor.Direction != model.Desc {
+			t.Error(cursor)
+		}
+	})
+	t.Run("no more data", func(t *testing.T) {
+		writer := httptest.NewRecorder()
+		err := setContinuationTokens(writer, request, results[1:2])
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		if _, ok := writer.Header()[continuationHeader]; ok {
+			t.Error("unexpected header", writer.Header().Get(continuationHeader))
+		}
+	})
+}
@@ -0,0 +1,44 @@
+/*
+ *    Copyright 2021 InfAI (CC SES)
+ *
+ *    Licensed under the Apache License, Version 2.0 (the "License");
+ *    you may not use this file except in compliance with the License.
+ *    You may obtain a copy of the License at
+ *
+ *        http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *    Unless required by applicable law or agreed to in writing, software
+ *    distributed under the License is distributed on an "AS IS" BASIS,
+ *    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *    See the License for the specific language governing permissions and
+ *    limitations under the License.
+ */
+
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/SENERGY-Platform/influx-wrapper/pkg/api/model"
+	"net/http"
+)
+
+// writes validation errors as application/problem+json, all other errors as plain text
+func writeBadRequest(writer http.ResponseWriter, err error) {
+	validationErrors, ok := err.(model.ValidationErrors)
+	if !ok {
+		http.Error(writer, err.Error(), http.StatusBadRequest)
+		return
+	}
+	writer.Header().Set("Content-Type", "application/problem+json")
+	writer.WriteHeader(http.StatusBadRequest)
+	err = json.NewEncoder(writer).Encode(model.ProblemDetails{
+		Type:   "about:blank",
+		Title:  "Invalid request body",
+		Status: http.StatusBadRequest,
+		Errors: validationErrors,
+	})
+	if err != nil {
+		fmt.Println("ERROR: " + err.Error())
+	}
+}
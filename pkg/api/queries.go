@@ -23,12 +23,15 @@ import (
 	"github.com/SENERGY-Platform/influx-wrapper/pkg/api/model"
 	"github.com/SENERGY-Platform/influx-wrapper/pkg/configuration"
 	influxdb "github.com/SENERGY-Platform/influx-wrapper/pkg/influx"
+	"github.com/SENERGY-Platform/influx-wrapper/pkg/metrics"
 	"github.com/julienschmidt/httprouter"
 	influxLib "github.com/orourkedd/influxdb1-client"
+	"github.com/orourkedd/influxdb1-client/models"
 	"log"
 	"net/http"
 	"sort"
 	"strconv"
+	"strings"
 	"time"
 )
 
@@ -39,79 +42,60 @@ func init() {
 func QueriesEndpoint(router *httprouter.Router, config configuration.Config, influx *influxdb.Influx) {
 	router.POST("/queries", func(writer http.ResponseWriter, request *http.Request, params httprouter.Params) {
 		start := time.Now()
-		requestedFormat := model.Format(request.URL.Query().Get("format"))
 		db := request.Header.Get(userHeader)
 		if db == "" {
 			http.Error(writer, "Missing header "+userHeader, http.StatusBadRequest)
 			return
 		}
 
-		var requestElements []model.QueriesRequestElement
-		err := json.NewDecoder(request.Body).Decode(&requestElements)
+		queriesRequest, err := parseQueriesRequest(request)
 		if err != nil {
-			http.Error(writer, err.Error(), http.StatusBadRequest)
+			writeBadRequest(writer, err)
 			return
 		}
-
-		paramOrderColumnIndex := request.URL.Query().Get("order_column_index")
-		var orderColumnIndex int
-		if paramOrderColumnIndex == "" {
-			orderColumnIndex = 0
-		} else {
-			orderColumnIndex, err = strconv.Atoi(paramOrderColumnIndex)
-			if err != nil {
-				http.Error(writer, "Invalid param order_column_index", http.StatusBadRequest)
-				return
-			}
+		requestElements := queriesRequest.elements
+		metrics.ObserveQueriesStatements(len(requestElements))
+		if queriesRequest.format == model.NDJSON {
+			streamQueries(writer, request, config, influx, db, queriesRequest)
+			return
 		}
-		orderDirection := model.Direction(request.URL.Query().Get("order_direction"))
-		if orderDirection == "" {
-			orderDirection = model.Desc
-		} else if orderDirection != "asc" && orderDirection != "desc" {
-			http.Error(writer, "Invalid param orderDirection", http.StatusBadRequest)
+		if queriesRequest.format == model.CSV && csvStreamable(queriesRequest) {
+			streamCSV(writer, request, config, influx, db, queriesRequest)
 			return
 		}
 
-		for i := range requestElements {
-			if !requestElements[i].Valid(requestedFormat) {
-				http.Error(writer, "Invalid request body", http.StatusBadRequest)
-				return
-			}
-		}
-		timeDirection := model.Desc
-		if orderColumnIndex == 0 {
-			timeDirection = orderDirection
-		}
-		query, err := influxdb.GenerateQueries(requestElements, timeDirection)
+		query, err := influxdb.GenerateQueries(requestElements, queriesRequest.timeDirection())
 		if err != nil {
 			http.Error(writer, err.Error(), http.StatusInternalServerError)
 			return
 		}
 
-		data, err := influx.ExecuteQuery(db, query)
+		ctx, cancel := queryContext(config, request)
+		defer cancel()
+		data, err := influx.ExecuteQuery(ctx, db, query)
 		if err != nil {
-			switch err {
-			case influxdb.ErrInfluxConnection, influxdb.ErrNULL:
-				http.Error(writer, err.Error(), http.StatusBadGateway)
-				return
-			case influxdb.ErrNotFound:
-				http.Error(writer, err.Error(), http.StatusNotFound)
-				return
-			default:
-				http.Error(writer, err.Error(), http.StatusInternalServerError)
-				return
-			}
+			writeInfluxError(writer, err)
+			return
 		}
 
-		timeFormat := request.URL.Query().Get("time_format")
-		response, err := formatResponse(requestedFormat, requestElements, data.Results, orderColumnIndex, orderDirection, timeFormat)
+		err = setContinuationTokens(writer, queriesRequest, data.Results)
+		if err != nil {
+			http.Error(writer, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		response, err := formatResponse(queriesRequest, data.Results)
 		if err != nil {
 			http.Error(writer, err.Error(), http.StatusInternalServerError)
 			return
 		}
 
-		writer.Header().Set("Content-Type", "application/json")
-		err = json.NewEncoder(writer).Encode(response)
+		metrics.ObserveResponseRows("/queries", countRows(response))
+		if queriesRequest.format == model.CSV {
+			err = writeCSV(writer, response.(csvTable))
+		} else {
+			writer.Header().Set("Content-Type", "application/json")
+			err = json.NewEncoder(writer).Encode(response)
+		}
 		if err != nil {
 			fmt.Println("ERROR: " + err.Error())
 		}
@@ -123,25 +107,153 @@ func QueriesEndpoint(router *httprouter.Router, config configuration.Config, inf
 
 }
 
-func formatResponse(f model.Format, request []model.QueriesRequestElement, results []influxLib.Result,
-	orderColumnIndex int, orderDirection model.Direction, timeFormat string) (data interface{}, err error) {
+type queriesRequest struct {
+	elements         []model.QueriesRequestElement
+	format           model.Format
+	orderColumnIndex int
+	orderDirection   model.Direction
+	timeFormat       timeFormat
+	join             model.JoinStrategy
+	joinTolerance    time.Duration
+	// location of the timezone param, used for the time column of the table and csv formats
+	location *time.Location
+	// locations of the elements, their own timezone or location
+	locations []*time.Location
+}
+
+// direction of the time column used when querying influx
+func (this queriesRequest) timeDirection() model.Direction {
+	if this.orderColumnIndex == 0 {
+		return this.orderDirection
+	}
+	return model.Desc
+}
+
+// parses and validates body and query params of a queries request. All errors are caused by invalid requests,
+// invalid request elements are reported as model.ValidationErrors.
+func parseQueriesRequest(request *http.Request) (parsed queriesRequest, err error) {
+	parsed.format = model.Format(request.URL.Query().Get("format"))
+	if parsed.format == "" && strings.Contains(request.Header.Get("Accept"), csvContentType) {
+		parsed.format = model.CSV
+	}
+	if parsed.format == "" {
+		parsed.format = model.PerQuery
+	}
+
+	err = json.NewDecoder(request.Body).Decode(&parsed.elements)
+	if err != nil {
+		return parsed, err
+	}
+
+	paramOrderColumnIndex := request.URL.Query().Get("order_column_index")
+	if paramOrderColumnIndex != "" {
+		parsed.orderColumnIndex, err = strconv.Atoi(paramOrderColumnIndex)
+		if err != nil {
+			return parsed, errors.New("Invalid param order_column_index")
+		}
+	}
+	parsed.orderDirection = model.Direction(request.URL.Query().Get("order_direction"))
+	if parsed.orderDirection == "" {
+		parsed.orderDirection = model.Desc
+	} else if parsed.orderDirection != model.Asc && parsed.orderDirection != model.Desc {
+		return parsed, errors.New("Invalid param orderDirection")
+	}
+
+	parsed.join = model.JoinStrategy(request.URL.Query().Get("join"))
+	if parsed.join == "" {
+		parsed.join = model.JoinNone
+	} else if !parsed.join.Valid() {
+		return parsed, errors.New("Invalid param join")
+	} else if parsed.format != model.Table && parsed.format != model.CSV {
+		return parsed, errors.New("Invalid param join, only allowed in format table and csv")
+	}
+	paramJoinTolerance := request.URL.Query().Get("join_tolerance")
+	if paramJoinTolerance != "" {
+		parsed.joinTolerance, err = time.ParseDuration(paramJoinTolerance)
+		if err != nil || parsed.joinTolerance < 0 {
+			return parsed, errors.New("Invalid param join_tolerance")
+		}
+	}
+
+	timezone := request.URL.Query().Get("timezone")
+	parsed.location, err = time.LoadLocation(timezone)
+	if err != nil || timezone == "Local" {
+		return parsed, errors.New("Invalid param timezone")
+	}
+	if timezone != "" {
+		for i := range parsed.elements {
+			if parsed.elements[i].Timezone == nil {
+				parsed.elements[i].Timezone = &timezone
+			}
+		}
+	}
+
+	var validationErrors model.ValidationErrors
+	for i := range parsed.elements {
+		validationErrors = append(validationErrors, parsed.elements[i].Validate(i, parsed.format)...)
+	}
+	if len(validationErrors) > 0 {
+		return parsed, validationErrors
+	}
+	timeDirection := parsed.timeDirection()
+	for i, element := range parsed.elements {
+		if element.Cursor == nil {
+			continue
+		}
+		// already validated
+		cursor, _ := model.ParseCursor(*element.Cursor)
+		if cursor.Direction != timeDirection {
+			validationErrors = validationErrors.Add(i, "["+strconv.Itoa(i)+"].cursor", "created for time direction "+string(cursor.Direction))
+		}
+	}
+	if len(validationErrors) > 0 {
+		return parsed, validationErrors
+	}
+	if parsed.format == model.NDJSON && parsed.orderColumnIndex != 0 {
+		return parsed, errors.New("Invalid param order_column_index, format ndjson is always ordered by time")
+	}
+	parsed.timeFormat, err = parseTimeFormat(request.URL.Query())
+	if err != nil {
+		return parsed, err
+	}
+	for _, element := range parsed.elements {
+		location := parsed.location
+		if element.Timezone != nil {
+			// already validated
+			location, _ = time.LoadLocation(*element.Timezone)
+		}
+		parsed.locations = append(parsed.locations, location)
+	}
+	return parsed, nil
+}
 
-	switch f {
-	case model.Table:
-		formatted, err := formatResponseAsTable(request, results, orderColumnIndex, orderDirection)
+func formatResponse(queriesRequest queriesRequest, results []influxLib.Result) (data interface{}, err error) {
+	request := queriesRequest.elements
+	timeFormat := queriesRequest.timeFormat
+	switch queriesRequest.format {
+	case model.Table, model.CSV:
+		blocks, err := tableBlocks(request, queriesRequest.location, results)
 		if err != nil {
 			return nil, err
 		}
-		if len(timeFormat) > 0 {
+		formatted, err := formatResponseAsTable(blocks, request, queriesRequest.join, queriesRequest.joinTolerance,
+			queriesRequest.orderColumnIndex, queriesRequest.orderDirection)
+		if err != nil {
+			return nil, err
+		}
+		if timeFormat.isSet() {
 			formatTime2D(formatted, timeFormat)
 		}
+		if queriesRequest.format == model.CSV {
+			return csvTable{header: csvHeader(request, blocks), rows: formatted}, nil
+		}
 		return formatted, nil
 	default:
-		formatted, err := formatResponsePerQuery(request, results)
+		formatted, err := formatResponsePerQuery(request, queriesRequest.locations, results)
 		if err != nil {
 			return nil, err
 		}
-		if len(timeFormat) > 0 {
+		if timeFormat.isSet() {
 			for i := range formatted {
 				formatTime2D(formatted[i], timeFormat)
 			}
@@ -150,73 +262,137 @@ func formatResponse(f model.Format, request []model.QueriesRequestElement, resul
 	}
 }
 
-func formatResponsePerQuery(request []model.QueriesRequestElement, results []influxLib.Result) (formatted [][][]interface{}, err error) {
+// rows of series grouped by tags are concatenated, the tag values are appended to each row
+func formatResponsePerQuery(request []model.QueriesRequestElement, locations []*time.Location, results []influxLib.Result) (formatted [][][]interface{}, err error) {
 	for index, result := range results {
 		if result.Series == nil {
 			// add empty column
 			formatted = append(formatted, [][]interface{}{})
 			continue
 		}
-		if len(result.Series) != 1 {
+		if len(result.Series) != 1 && len(request[index].GroupTags) == 0 {
 			return nil, errors.New("unexpected number of series")
 		}
-		// add data
-		for rowIndex := range result.Series[0].Values {
-			result.Series[0].Values[rowIndex][0], err = time.Parse(time.RFC3339, result.Series[0].Values[rowIndex][0].(string))
+		rows := [][]interface{}{}
+		for _, series := range result.Series {
+			seriesRows, err := formatSeries(request[index], locations[index], series)
 			if err != nil {
 				return nil, err
 			}
+			rows = append(rows, seriesRows...)
 		}
-		err = model.Sort2D(result.Series[0].Values, *request[index].OrderColumnIndex, *request[index].OrderDirection)
+		err = model.Sort2D(rows, *request[index].OrderColumnIndex, *request[index].OrderDirection)
 		if err != nil {
 			return nil, err
 		}
-		formatted = append(formatted, result.Series[0].Values)
+		formatted = append(formatted, rows)
 	}
 	return
 }
 
-func formatResponseAsTable(request []model.QueriesRequestElement, results []influxLib.Result, orderColumnIndex int, orderDirection model.Direction) (formatted [][]interface{}, err error) {
-	data, err := formatResponsePerQuery(request, results)
-	if err != nil {
-		return nil, err
+// parses the time column in location and appends the values of the grouped tags to each row
+func formatSeries(element model.QueriesRequestElement, location *time.Location, series models.Row) ([][]interface{}, error) {
+	for rowIndex := range series.Values {
+		t, err := time.Parse(time.RFC3339, series.Values[rowIndex][0].(string))
+		if err != nil {
+			return nil, err
+		}
+		series.Values[rowIndex][0] = t.In(location)
+		for _, tag := range element.GroupTags {
+			series.Values[rowIndex] = append(series.Values[rowIndex], series.Tags[tag])
+		}
 	}
+	return series.Values, nil
+}
 
-	totalColumns := 1
-	baseIndex := map[int]int{}
-	for requestIndex, element := range request {
-		baseIndex[requestIndex] = totalColumns
-		totalColumns += len(element.Columns)
-	}
-
-	for seriesIndex := range data {
-		for rowIndex := range data[seriesIndex] {
-			formattedRow := make([]interface{}, totalColumns)
-			formattedRow[0] = data[seriesIndex][rowIndex][0]
-			for seriesColumnIndex := range request[seriesIndex].Columns {
-				formattedRow[baseIndex[seriesIndex]+seriesColumnIndex] = data[seriesIndex][rowIndex][seriesColumnIndex+1]
+// a tableBlock holds the rows of a single series. Each block is placed in its own columns of a table.
+type tableBlock struct {
+	element int
+	tags    []string
+	rows    [][]interface{}
+}
+
+func (this tableBlock) width(request []model.QueriesRequestElement) int {
+	return len(request[this.element].Columns) + len(request[this.element].GroupTags)
+}
+
+// creates one block per request element and tag combination. Blocks of an element are sorted by their tag values,
+// elements without series still get a single, empty block. All times are converted to location, so rows of different
+// elements can be merged.
+func tableBlocks(request []model.QueriesRequestElement, location *time.Location, results []influxLib.Result) (blocks []tableBlock, err error) {
+	for index, result := range results {
+		if len(result.Series) == 0 {
+			blocks = append(blocks, tableBlock{element: index})
+			continue
+		}
+		if len(result.Series) != 1 && len(request[index].GroupTags) == 0 {
+			return nil, errors.New("unexpected number of series")
+		}
+		elementBlocks := []tableBlock{}
+		for _, series := range result.Series {
+			rows, err := formatSeries(request[index], location, series)
+			if err != nil {
+				return nil, err
 			}
-			for subSeriesIndex := range data {
-				if subSeriesIndex <= seriesIndex {
-					continue
-				}
-				subRowIndex, ok := findFirstElementIndex(data[subSeriesIndex], data[seriesIndex][rowIndex][0].(time.Time), 0, len(data[subSeriesIndex])-1)
-				if ok {
-					if data[subSeriesIndex][subRowIndex][0] == data[seriesIndex][rowIndex][0] {
-						for subSeriesColumnIndex := range request[subSeriesIndex].Columns {
-							formattedRow[baseIndex[subSeriesIndex]+subSeriesColumnIndex] = data[subSeriesIndex][subRowIndex][subSeriesColumnIndex+1]
-						}
-						data[subSeriesIndex] = model.RemoveElementFrom2D(data[subSeriesIndex], subRowIndex)
-						// sorting required for binary search
-						sort.Slice(data[subSeriesIndex], func(i, j int) bool {
-							return data[subSeriesIndex][i][0].(time.Time).After(data[subSeriesIndex][j][0].(time.Time))
-						})
-						continue
-					}
+			block := tableBlock{element: index, rows: rows}
+			for _, tag := range request[index].GroupTags {
+				block.tags = append(block.tags, series.Tags[tag])
+			}
+			elementBlocks = append(elementBlocks, block)
+		}
+		sort.SliceStable(elementBlocks, func(i, j int) bool {
+			for k := range elementBlocks[i].tags {
+				if elementBlocks[i].tags[k] != elementBlocks[j].tags[k] {
+					return elementBlocks[i].tags[k] < elementBlocks[j].tags[k]
 				}
 			}
-			formatted = append(formatted, formattedRow)
+			return false
+		})
+		blocks = append(blocks, elementBlocks...)
+	}
+	return blocks, nil
+}
+
+// merges the rows of all blocks by time. The tag columns of a block are filled in every row of the table, empty cells
+// of the value columns are filled according to join.
+func formatResponseAsTable(blocks []tableBlock, request []model.QueriesRequestElement, join model.JoinStrategy,
+	joinTolerance time.Duration, orderColumnIndex int, orderDirection model.Direction) (formatted [][]interface{}, err error) {
+	totalColumns := 1
+	baseIndex := make([]int, len(blocks))
+	data := make([][][]interface{}, len(blocks))
+	for blockIndex, block := range blocks {
+		baseIndex[blockIndex] = totalColumns
+		totalColumns += block.width(request)
+		data[blockIndex] = block.rows
+		model.SortByTime(data[blockIndex])
+	}
+
+	template := make([]interface{}, totalColumns)
+	for blockIndex, block := range blocks {
+		columns := len(request[block.element].Columns)
+		for tagIndex, tag := range block.tags {
+			template[baseIndex[blockIndex]+columns+tagIndex] = tag
+		}
+	}
+
+	formatted = model.MergeByTime(data, baseIndex, template)
+	if join != model.JoinNone {
+		valueColumns := []int{}
+		for blockIndex, block := range blocks {
+			for i := range request[block.element].Columns {
+				valueColumns = append(valueColumns, baseIndex[blockIndex]+i)
+			}
+		}
+		model.FillGaps2D(formatted, valueColumns, join, joinTolerance)
+	}
+	if orderColumnIndex == 0 {
+		// already sorted by time ascending
+		if orderDirection == model.Desc {
+			for i, j := 0, len(formatted)-1; i < j; i, j = i+1, j-1 {
+				formatted[i], formatted[j] = formatted[j], formatted[i]
+			}
 		}
+		return formatted, nil
 	}
 	err = model.Sort2D(formatted, orderColumnIndex, orderDirection)
 	if err != nil {
@@ -225,26 +401,23 @@ func formatResponseAsTable(request []model.QueriesRequestElement, results []infl
 	return
 }
 
-func findFirstElementIndex(array [][]interface{}, t time.Time, left int, right int) (int, bool) {
-	if left > right {
-		return 0, false
-	}
-	mid := left + (right-left)/2
-	if t.Equal(array[mid][0].(time.Time)) {
-		for t.Equal(array[mid][0].(time.Time)) && mid > 0 {
-			mid--
+// counts the rows of formatted responses
+func countRows(data interface{}) (rows int) {
+	switch formatted := data.(type) {
+	case [][]interface{}:
+		return len(formatted)
+	case csvTable:
+		return len(formatted.rows)
+	case [][][]interface{}:
+		for i := range formatted {
+			rows += len(formatted[i])
 		}
-		return mid, true
-	}
-
-	if array[mid][0].(time.Time).Before(t) {
-		return findFirstElementIndex(array, t, left, mid-1)
 	}
-	return findFirstElementIndex(array, t, mid+1, right)
+	return rows
 }
 
-func formatTime2D(data [][]interface{}, timeFormat string) {
+func formatTime2D(data [][]interface{}, timeFormat timeFormat) {
 	for i := range data {
-		data[i][0] = data[i][0].(time.Time).Format(timeFormat)
+		data[i][0] = timeFormat.format(data[i][0].(time.Time))
 	}
 }
@@ -0,0 +1,151 @@
+/*
+ *    Copyright 2021 InfAI (CC SES)
+ *
+ *    Licensed under the Apache License, Version 2.0 (the "License");
+ *    you may not use this file except in compliance with the License.
+ *    You may obtain a copy of the License at
+ *
+ *        http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *    Unless required by applicable law or agreed to in writing, software
+ *    distributed under the License is distributed on an "AS IS" BASIS,
+ *    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *    See the License for the specific language governing permissions and
+ *    limitations under the License.
+ */
+
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/SENERGY-Platform/influx-wrapper/pkg/api/model"
+	"github.com/SENERGY-Platform/influx-wrapper/pkg/configuration"
+	influxdb "github.com/SENERGY-Platform/influx-wrapper/pkg/influx"
+	"github.com/julienschmidt/httprouter"
+	"log"
+	"net/http"
+	"time"
+)
+
+func init() {
+	endpoints = append(endpoints, RetentionPolicyEndpoint)
+}
+
+// RetentionPolicyEndpoint lists, creates and alters the retention policies of the database of the user within the
+// configured limits. Changes are written to the audit log.
+func RetentionPolicyEndpoint(router *httprouter.Router, config configuration.Config, influx *influxdb.Influx) {
+	limits := retentionPolicyLimits(config)
+
+	router.GET("/retention-policies", func(writer http.ResponseWriter, request *http.Request, params httprouter.Params) {
+		start := time.Now()
+		db := request.Header.Get(userHeader)
+		if db == "" {
+			http.Error(writer, "Missing header "+userHeader, http.StatusBadRequest)
+			return
+		}
+
+		ctx, cancel := queryContext(config, request)
+		defer cancel()
+		policies, err := influx.GetRetentionPolicies(ctx, db)
+		if err != nil {
+			writeInfluxError(writer, err)
+			return
+		}
+		writer.Header().Set("Content-Type", "application/json")
+		err = json.NewEncoder(writer).Encode(policies)
+		if err != nil {
+			fmt.Println("ERROR: " + err.Error())
+		}
+
+		if config.Debug {
+			log.Println("Took " + time.Since(start).String())
+		}
+	})
+
+	router.POST("/retention-policies", func(writer http.ResponseWriter, request *http.Request, params httprouter.Params) {
+		start := time.Now()
+		db := request.Header.Get(userHeader)
+		if db == "" {
+			http.Error(writer, "Missing header "+userHeader, http.StatusBadRequest)
+			return
+		}
+		policyRequest := model.RetentionPolicyRequest{}
+		err := json.NewDecoder(request.Body).Decode(&policyRequest)
+		if err != nil {
+			http.Error(writer, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if validationErrors := policyRequest.Validate(true, limits); len(validationErrors) > 0 {
+			writeBadRequest(writer, validationErrors)
+			return
+		}
+
+		ctx, cancel := queryContext(config, request)
+		defer cancel()
+		statement, err := influx.CreateRetentionPolicy(ctx, db, policyRequest, limits.MaxCount)
+		auditRetentionPolicy(config, request, "create_retention_policy", statement, err)
+		if err != nil {
+			writeInfluxError(writer, err)
+			return
+		}
+		writer.WriteHeader(http.StatusCreated)
+
+		if config.Debug {
+			log.Println("Took " + time.Since(start).String())
+		}
+	})
+
+	router.PATCH("/retention-policies/:name", func(writer http.ResponseWriter, request *http.Request, params httprouter.Params) {
+		start := time.Now()
+		db := request.Header.Get(userHeader)
+		if db == "" {
+			http.Error(writer, "Missing header "+userHeader, http.StatusBadRequest)
+			return
+		}
+		policyRequest := model.RetentionPolicyRequest{}
+		err := json.NewDecoder(request.Body).Decode(&policyRequest)
+		if err != nil {
+			http.Error(writer, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if validationErrors := policyRequest.Validate(false, limits); len(validationErrors) > 0 {
+			writeBadRequest(writer, validationErrors)
+			return
+		}
+
+		ctx, cancel := queryContext(config, request)
+		defer cancel()
+		statement, err := influx.AlterRetentionPolicy(ctx, db, params.ByName("name"), policyRequest)
+		auditRetentionPolicy(config, request, "alter_retention_policy", statement, err)
+		if err != nil {
+			writeInfluxError(writer, err)
+			return
+		}
+		writer.WriteHeader(http.StatusNoContent)
+
+		if config.Debug {
+			log.Println("Took " + time.Since(start).String())
+		}
+	})
+}
+
+// retentionPolicyLimits reads the limits from config, which are validated on start
+func retentionPolicyLimits(config configuration.Config) (limits model.RetentionPolicyLimits) {
+	limits.MaxCount = int(config.RetentionPolicyMaxCount)
+	limits.MinDuration, _ = time.ParseDuration(config.RetentionPolicyMinDuration)
+	limits.MaxDuration, _ = time.ParseDuration(config.RetentionPolicyMaxDuration)
+	return limits
+}
+
+// auditRetentionPolicy writes executed statements to the audit log, including their error
+func auditRetentionPolicy(config configuration.Config, request *http.Request, action string, statement string, err error) {
+	if statement == "" {
+		return
+	}
+	entry := auditEntry{Action: action, Statement: statement}
+	if err != nil {
+		entry.Error = err.Error()
+	}
+	audit(config, request, entry)
+}
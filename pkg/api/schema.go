@@ -0,0 +1,160 @@
+/*
+ *    Copyright 2021 InfAI (CC SES)
+ *
+ *    Licensed under the Apache License, Version 2.0 (the "License");
+ *    you may not use this file except in compliance with the License.
+ *    You may obtain a copy of the License at
+ *
+ *        http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *    Unless required by applicable law or agreed to in writing, software
+ *    distributed under the License is distributed on an "AS IS" BASIS,
+ *    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *    See the License for the specific language governing permissions and
+ *    limitations under the License.
+ */
+
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/SENERGY-Platform/influx-wrapper/pkg/api/model"
+	"github.com/SENERGY-Platform/influx-wrapper/pkg/configuration"
+	influxdb "github.com/SENERGY-Platform/influx-wrapper/pkg/influx"
+	"github.com/julienschmidt/httprouter"
+	"log"
+	"net/http"
+	"net/url"
+	"regexp"
+	"time"
+)
+
+func init() {
+	endpoints = append(endpoints, SchemaEndpoint)
+}
+
+// SchemaEndpoint lists measurements, field keys and tag keys of the database of the user
+func SchemaEndpoint(router *httprouter.Router, config configuration.Config, influx *influxdb.Influx) {
+	router.GET("/measurements", func(writer http.ResponseWriter, request *http.Request, params httprouter.Params) {
+		start := time.Now()
+		db := request.Header.Get(userHeader)
+		if db == "" {
+			http.Error(writer, "Missing header "+userHeader, http.StatusBadRequest)
+			return
+		}
+		limit, offset, err := parsePage(request.URL.Query())
+		if err != nil {
+			http.Error(writer, err.Error(), http.StatusBadRequest)
+			return
+		}
+		regex, err := parseNameFilter(request.URL.Query())
+		if err != nil {
+			http.Error(writer, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		ctx, cancel := queryContext(config, request)
+		defer cancel()
+		measurements, err := influx.GetMeasurements(ctx, db, regex, limit+1, offset)
+		if err != nil {
+			writeInfluxError(writer, err)
+			return
+		}
+		page := model.MeasurementsPage{Measurements: measurements, NextOffset: nextOffset(limit, offset, len(measurements))}
+		if page.NextOffset != nil {
+			page.Measurements = page.Measurements[:limit]
+		}
+		writeSchemaPage(writer, page)
+
+		if config.Debug {
+			log.Println("Took " + time.Since(start).String())
+		}
+	})
+
+	router.GET("/measurements/:id/field-keys", func(writer http.ResponseWriter, request *http.Request, params httprouter.Params) {
+		start := time.Now()
+		db := request.Header.Get(userHeader)
+		if db == "" {
+			http.Error(writer, "Missing header "+userHeader, http.StatusBadRequest)
+			return
+		}
+		limit, offset, err := parsePage(request.URL.Query())
+		if err != nil {
+			http.Error(writer, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		ctx, cancel := queryContext(config, request)
+		defer cancel()
+		fieldKeys, err := influx.GetFieldKeys(ctx, db, params.ByName("id"), limit+1, offset)
+		if err != nil {
+			writeInfluxError(writer, err)
+			return
+		}
+		page := model.FieldKeysPage{FieldKeys: fieldKeys, NextOffset: nextOffset(limit, offset, len(fieldKeys))}
+		if page.NextOffset != nil {
+			page.FieldKeys = page.FieldKeys[:limit]
+		}
+		writeSchemaPage(writer, page)
+
+		if config.Debug {
+			log.Println("Took " + time.Since(start).String())
+		}
+	})
+
+	router.GET("/measurements/:id/tag-keys", func(writer http.ResponseWriter, request *http.Request, params httprouter.Params) {
+		start := time.Now()
+		db := request.Header.Get(userHeader)
+		if db == "" {
+			http.Error(writer, "Missing header "+userHeader, http.StatusBadRequest)
+			return
+		}
+		limit, offset, err := parsePage(request.URL.Query())
+		if err != nil {
+			http.Error(writer, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		ctx, cancel := queryContext(config, request)
+		defer cancel()
+		tagKeys, err := influx.GetTagKeys(ctx, db, params.ByName("id"), limit+1, offset)
+		if err != nil {
+			writeInfluxError(writer, err)
+			return
+		}
+		page := model.TagKeysPage{TagKeys: tagKeys, NextOffset: nextOffset(limit, offset, len(tagKeys))}
+		if page.NextOffset != nil {
+			page.TagKeys = page.TagKeys[:limit]
+		}
+		writeSchemaPage(writer, page)
+
+		if config.Debug {
+			log.Println("Took " + time.Since(start).String())
+		}
+	})
+}
+
+// parseNameFilter returns a regular expression built from the query params prefix or regex, empty if neither is set
+func parseNameFilter(query url.Values) (string, error) {
+	if query.Has("prefix") && query.Has("regex") {
+		return "", errors.New("Invalid params, use either prefix or regex")
+	}
+	if query.Get("prefix") != "" {
+		return influxdb.PrefixRegex(query.Get("prefix")), nil
+	}
+	regex := query.Get("regex")
+	if _, err := regexp.Compile(regex); err != nil {
+		return "", fmt.Errorf("Invalid param regex: %w", err)
+	}
+	return regex, nil
+}
+
+func writeSchemaPage(writer http.ResponseWriter, page interface{}) {
+	writer.Header().Set("Content-Type", "application/json")
+	err := json.NewEncoder(writer).Encode(page)
+	if err != nil {
+		fmt.Println("ERROR: " + err.Error())
+	}
+}
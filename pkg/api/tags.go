@@ -18,12 +18,15 @@ package api
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
+	"github.com/SENERGY-Platform/influx-wrapper/pkg/api/model"
 	"github.com/SENERGY-Platform/influx-wrapper/pkg/configuration"
 	influxdb "github.com/SENERGY-Platform/influx-wrapper/pkg/influx"
 	"github.com/julienschmidt/httprouter"
 	"log"
 	"net/http"
+	"net/url"
 	"time"
 )
 
@@ -41,20 +44,36 @@ func TagsEndpoint(router *httprouter.Router, config configuration.Config, influx
 			return
 		}
 
-		tagMap, err := influx.GetTags(db, id)
+		ctx, cancel := queryContext(config, request)
+		defer cancel()
 
-		if err != nil {
-			switch err {
-			case influxdb.ErrInfluxConnection, influxdb.ErrNULL:
-				http.Error(writer, err.Error(), http.StatusBadGateway)
+		if tagsFiltered(request.URL.Query()) {
+			filter, err := parseTagFilter(request.URL.Query())
+			if err != nil {
+				http.Error(writer, err.Error(), http.StatusBadRequest)
 				return
-			case influxdb.ErrNotFound:
-				http.Error(writer, err.Error(), http.StatusNotFound)
+			}
+			limit, offset, err := parsePage(request.URL.Query())
+			if err != nil {
+				http.Error(writer, err.Error(), http.StatusBadRequest)
 				return
-			default:
-				http.Error(writer, err.Error(), http.StatusInternalServerError)
+			}
+			values, counts, err := influx.GetTagValues(ctx, db, id, filter, limit, offset)
+			if err != nil {
+				writeInfluxError(writer, err)
 				return
 			}
+			writeSchemaPage(writer, model.NewTagsPage(values, counts, limit, offset))
+			if config.Debug {
+				log.Println("Took " + time.Since(start).String())
+			}
+			return
+		}
+
+		tagMap, err := influx.GetTags(ctx, db, id)
+		if err != nil {
+			writeInfluxError(writer, err)
+			return
 		}
 
 		writer.Header().Set("Content-Type", "application/json")
@@ -69,3 +88,47 @@ func TagsEndpoint(router *httprouter.Router, config configuration.Config, influx
 	})
 
 }
+
+// tagFilterParams switch /tags/:id to the paginated response with counts
+var tagFilterParams = []string{"key", "prefix", "regex", "last", "start", "end", "limit", "offset"}
+
+func tagsFiltered(query url.Values) bool {
+	for _, param := range tagFilterParams {
+		if query.Has(param) {
+			return true
+		}
+	}
+	return false
+}
+
+func parseTagFilter(query url.Values) (filter influxdb.TagFilter, err error) {
+	for _, key := range query["key"] {
+		if key == "" {
+			return filter, errors.New("Invalid param key, must not be empty")
+		}
+		filter.Keys = append(filter.Keys, key)
+	}
+	filter.ValueRegex, err = parseNameFilter(query)
+	if err != nil {
+		return filter, err
+	}
+	if query.Has("last") || query.Has("start") || query.Has("end") {
+		filter.Time = &model.QueriesRequestElementTime{}
+		if query.Has("last") {
+			last := query.Get("last")
+			filter.Time.Last = &last
+		}
+		if query.Has("start") {
+			start := query.Get("start")
+			filter.Time.Start = &start
+		}
+		if query.Has("end") {
+			end := query.Get("end")
+			filter.Time.End = &end
+		}
+		if errs := filter.Time.Validate(0, "time"); len(errs) > 0 {
+			return filter, errors.New("Invalid time range, " + errs.Error())
+		}
+	}
+	return filter, nil
+}
@@ -0,0 +1,94 @@
+/*
+ *    Copyright 2021 InfAI (CC SES)
+ *
+ *    Licensed under the Apache License, Version 2.0 (the "License");
+ *    you may not use this file except in compliance with the License.
+ *    You may obtain a copy of the License at
+ *
+ *        http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *    Unless required by applicable law or agreed to in writing, software
+ *    distributed under the License is distributed on an "AS IS" BASIS,
+ *    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *    See the License for the specific language governing permissions and
+ *    limitations under the License.
+ */
+
+package api
+
+import (
+	"errors"
+	"net/url"
+	"time"
+)
+
+// named layouts usable as time_format
+var timeFormatPresets = map[string]string{
+	"rfc3339":     time.RFC3339,
+	"rfc3339nano": time.RFC3339Nano,
+	// local time of the requested timezone, without offset
+	"iso_local": "2006-01-02T15:04:05.999999999",
+}
+
+var epochUnits = map[string]time.Duration{
+	"s":  time.Second,
+	"ms": time.Millisecond,
+	"us": time.Microsecond,
+	"ns": time.Nanosecond,
+}
+
+// timeFormat holds the output format of timestamps set by the query params time_format or epoch. If neither is set,
+// timestamps are returned as they are.
+type timeFormat struct {
+	layout string
+	epoch  time.Duration
+	// epochName is the value of the epoch param
+	epochName string
+}
+
+func parseTimeFormat(query url.Values) (format timeFormat, err error) {
+	format.layout = query.Get("time_format")
+	if preset, ok := timeFormatPresets[format.layout]; ok {
+		format.layout = preset
+	} else if format.layout != "" && time.Unix(0, 0).UTC().Format(format.layout) == format.layout {
+		// neither a preset nor a layout containing any element of the reference time
+		return format, errors.New("Invalid param time_format, use a preset or a layout of the reference time")
+	}
+	format.epochName = query.Get("epoch")
+	if format.epochName != "" {
+		var ok bool
+		format.epoch, ok = epochUnits[format.epochName]
+		if !ok {
+			return format, errors.New("Invalid param epoch, use one of s, ms, us, ns")
+		}
+		if format.layout != "" {
+			return format, errors.New("Invalid param epoch, can not be combined with time_format")
+		}
+	}
+	return format, nil
+}
+
+func (this timeFormat) isSet() bool {
+	return this.layout != "" || this.epoch != 0
+}
+
+// format returns an integer for epochs, a string otherwise. Layouts are applied in the location of t.
+func (this timeFormat) format(t time.Time) interface{} {
+	if this.epoch != 0 {
+		return t.UnixNano() / int64(this.epoch)
+	}
+	return t.Format(this.layout)
+}
+
+// formatString formats a rfc3339 timestamp as returned by influx in location
+func (this timeFormat) formatString(value interface{}, location *time.Location) (interface{}, error) {
+	str, ok := value.(string)
+	if !ok || !this.isSet() {
+		return value, nil
+	}
+	t, err := time.Parse(time.RFC3339, str)
+	if err != nil {
+		return nil, err
+	}
+	return this.format(t.In(location)), nil
+}
@@ -0,0 +1,83 @@
+/*
+ *    Copyright 2021 InfAI (CC SES)
+ *
+ *    Licensed under the Apache License, Version 2.0 (the "License");
+ *    you may not use this file except in compliance with the License.
+ *    You may obtain a copy of the License at
+ *
+ *        http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *    Unless required by applicable law or agreed to in writing, software
+ *    distributed under the License is distributed on an "AS IS" BASIS,
+ *    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *    See the License for the specific language governing permissions and
+ *    limitations under the License.
+ */
+
+package api
+
+import (
+	"net/url"
+	"testing"
+	"time"
+)
+
+func TestTimeFormat(t *testing.T) {
+	berlin, err := time.LoadLocation("Europe/Berlin")
+	if err != nil {
+		t.Fatal(err)
+	}
+	value := "2021-07-01T10:00:00.123456789Z"
+	cases := []struct {
+		query    string
+		location *time.Location
+		expect   interface{}
+	}{
+		{"", time.UTC, value},
+		{"", berlin, value},
+		{"time_format=rfc3339", time.UTC, "2021-07-01T10:00:00Z"},
+		{"time_format=rfc3339", berlin, "2021-07-01T12:00:00+02:00"},
+		{"time_format=rfc3339nano", time.UTC, "2021-07-01T10:00:00.123456789Z"},
+		{"time_format=rfc3339nano", berlin, "2021-07-01T12:00:00.123456789+02:00"},
+		{"time_format=iso_local", time.UTC, "2021-07-01T10:00:00.123456789"},
+		{"time_format=iso_local", berlin, "2021-07-01T12:00:00.123456789"},
+		{"time_format=" + url.QueryEscape("02.01.2006 15:04"), berlin, "01.07.2021 12:00"},
+		{"epoch=s", time.UTC, int64(1625133600)},
+		{"epoch=ms", time.UTC, int64(1625133600123)},
+		{"epoch=us", time.UTC, int64(1625133600123456)},
+		{"epoch=ns", time.UTC, int64(1625133600123456789)},
+		// epochs do not depend on the timezone
+		{"epoch=ms", berlin, int64(1625133600123)},
+	}
+	for _, c := range cases {
+		query, _ := url.ParseQuery(c.query)
+		format, err := parseTimeFormat(query)
+		if err != nil {
+			t.Error(c.query, err)
+			continue
+		}
+		actual, err := format.formatString(value, c.location)
+		if err != nil {
+			t.Error(c.query, err)
+			continue
+		}
+		if actual != c.expect {
+			t.Error(c.query, c.location, "expected", c.expect, "actual", actual)
+		}
+	}
+
+	t.Run("invalid", func(t *testing.T) {
+		for _, query := range []string{"time_format=unix", "time_format=iso", "epoch=m", "epoch=seconds", "epoch=s&time_format=rfc3339"} {
+			values, _ := url.ParseQuery(query)
+			if _, err := parseTimeFormat(values); err == nil {
+				t.Error(query, "expected error")
+			}
+		}
+	})
+	t.Run("invalid value", func(t *testing.T) {
+		format, _ := parseTimeFormat(url.Values{"epoch": {"s"}})
+		if _, err := format.formatString("yesterday", time.UTC); err == nil {
+			t.Error("expected error")
+		}
+	})
+}
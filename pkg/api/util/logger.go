@@ -65,3 +65,8 @@ func (this *ResponseWriterWithStatusCodeLog) WriteHeader(statusCode int) {
 	this.Status = statusCode
 	this.Parent.WriteHeader(statusCode)
 }
+
+// Unwrap allows http.ResponseController to flush and set deadlines on the parent
+func (this *ResponseWriterWithStatusCodeLog) Unwrap() http.ResponseWriter {
+	return this.Parent
+}
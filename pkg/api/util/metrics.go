@@ -0,0 +1,41 @@
+/*
+ *    Copyright 2021 InfAI (CC SES)
+ *
+ *    Licensed under the Apache License, Version 2.0 (the "License");
+ *    you may not use this file except in compliance with the License.
+ *    You may obtain a copy of the License at
+ *
+ *        http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *    Unless required by applicable law or agreed to in writing, software
+ *    distributed under the License is distributed on an "AS IS" BASIS,
+ *    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *    See the License for the specific language governing permissions and
+ *    limitations under the License.
+ */
+
+package util
+
+import (
+	"github.com/SENERGY-Platform/influx-wrapper/pkg/metrics"
+	"net/http"
+	"time"
+)
+
+// NewMetrics records request counts and latencies. route maps a request to the route it is handled by,
+// so request paths with parameters do not create a new time series each.
+func NewMetrics(handler http.Handler, route func(request *http.Request) string) *MetricsMiddleware {
+	return &MetricsMiddleware{handler: handler, route: route}
+}
+
+type MetricsMiddleware struct {
+	handler http.Handler
+	route   func(request *http.Request) string
+}
+
+func (this *MetricsMiddleware) ServeHTTP(w http.ResponseWriter, request *http.Request) {
+	response := &ResponseWriterWithStatusCodeLog{Parent: w, Status: 200}
+	start := time.Now()
+	this.handler.ServeHTTP(response, request)
+	metrics.ObserveRequest(this.route(request), request.Method, response.Status, time.Since(start))
+}
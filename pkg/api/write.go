@@ -0,0 +1,195 @@
+/*
+ *    Copyright 2021 InfAI (CC SES)
+ *
+ *    Licensed under the Apache License, Version 2.0 (the "License");
+ *    you may not use this file except in compliance with the License.
+ *    You may obtain a copy of the License at
+ *
+ *        http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *    Unless required by applicable law or agreed to in writing, software
+ *    distributed under the License is distributed on an "AS IS" BASIS,
+ *    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *    See the License for the specific language governing permissions and
+ *    limitations under the License.
+ */
+
+package api
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/SENERGY-Platform/influx-wrapper/pkg/api/model"
+	"github.com/SENERGY-Platform/influx-wrapper/pkg/configuration"
+	influxdb "github.com/SENERGY-Platform/influx-wrapper/pkg/influx"
+	"github.com/julienschmidt/httprouter"
+	"github.com/orourkedd/influxdb1-client/models"
+	"io"
+	"log"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+func init() {
+	endpoints = append(endpoints, WriteEndpoint)
+}
+
+const (
+	defaultWriteMaxPoints = 50000
+	maxWriteBodySize      = 64 << 20
+)
+
+// WriteEndpoint writes points to the database of the user. Bodies with content type application/json are read as
+// array of model.WritePoint, all other bodies as line protocol.
+func WriteEndpoint(router *httprouter.Router, config configuration.Config, influx *influxdb.Influx) {
+	router.POST("/write", func(writer http.ResponseWriter, request *http.Request, params httprouter.Params) {
+		start := time.Now()
+
+		db := request.Header.Get(userHeader)
+		if db == "" {
+			http.Error(writer, "Missing header "+userHeader, http.StatusBadRequest)
+			return
+		}
+		maxPoints := int(config.WriteMaxPoints)
+		if maxPoints <= 0 {
+			maxPoints = defaultWriteMaxPoints
+		}
+		ctx, cancel := writeContext(config, request)
+		defer cancel()
+		err := extendDeadlines(ctx, writer)
+		if err != nil {
+			http.Error(writer, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		body := http.MaxBytesReader(writer, request.Body, maxWriteBodySize)
+
+		var points []models.Point
+		var pointErrors []model.WritePointError
+		var lines []int
+		jsonBody := strings.HasPrefix(request.Header.Get("Content-Type"), "application/json")
+		if jsonBody {
+			points, pointErrors, err = parseJSONPoints(body, start)
+		} else {
+			points, lines, pointErrors, err = parseLineProtocol(body, request.URL.Query().Get("precision"), start)
+		}
+		if err != nil {
+			var maxBytesError *http.MaxBytesError
+			if errors.As(err, &maxBytesError) {
+				http.Error(writer, "Body too large", http.StatusRequestEntityTooLarge)
+				return
+			}
+			http.Error(writer, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if len(points) > maxPoints {
+			http.Error(writer, "Too many points, at most "+strconv.Itoa(maxPoints)+" are allowed", http.StatusRequestEntityTooLarge)
+			return
+		}
+
+		errs, err := influx.WritePoints(ctx, db, points, jsonBody, int(config.WriteBatchSize))
+		if err != nil {
+			writeInfluxError(writer, err)
+			return
+		}
+		for i, err := range errs {
+			if err != nil {
+				pointErrors = append(pointErrors, model.WritePointError{Index: i, Error: err.Error()})
+			}
+		}
+		if lines != nil {
+			for i := range pointErrors {
+				pointErrors[i].Line = lines[pointErrors[i].Index]
+			}
+		}
+		sortWritePointErrors(pointErrors)
+		response := model.WriteResponse{
+			Written: len(points) - len(pointErrors),
+			Failed:  len(pointErrors),
+			Errors:  pointErrors,
+		}
+
+		writer.Header().Set("Content-Type", "application/json")
+		if response.Written == 0 && response.Failed > 0 {
+			writer.WriteHeader(http.StatusBadRequest)
+		}
+		err = json.NewEncoder(writer).Encode(response)
+		if err != nil {
+			fmt.Println("ERROR: " + err.Error())
+		}
+
+		if config.Debug {
+			log.Println("Took " + time.Since(start).String())
+		}
+	})
+}
+
+// extendDeadlines extends the read and write deadlines of the server to the deadline of ctx, so large bodies sent
+// slowly are only limited by the write timeout
+func extendDeadlines(ctx context.Context, writer http.ResponseWriter) error {
+	controller := http.NewResponseController(writer)
+	// no deadline if ctx has none
+	deadline, _ := ctx.Deadline()
+	for _, setDeadline := range []func(time.Time) error{controller.SetReadDeadline, controller.SetWriteDeadline} {
+		err := setDeadline(deadline)
+		if err != nil && !errors.Is(err, http.ErrNotSupported) {
+			return err
+		}
+	}
+	return nil
+}
+
+// parseJSONPoints returns a point for each element of the body, nil for invalid points which are reported in pointErrors
+func parseJSONPoints(body io.Reader, now time.Time) (points []models.Point, pointErrors []model.WritePointError, err error) {
+	var writePoints []model.WritePoint
+	decoder := json.NewDecoder(body)
+	decoder.UseNumber()
+	err = decoder.Decode(&writePoints)
+	if err != nil {
+		return nil, nil, err
+	}
+	points = make([]models.Point, len(writePoints))
+	for i, writePoint := range writePoints {
+		points[i], err = influxdb.JSONPoint(writePoint, now)
+		if err != nil {
+			pointErrors = append(pointErrors, model.WritePointError{Index: i, Error: err.Error()})
+		}
+	}
+	return points, pointErrors, nil
+}
+
+// parseLineProtocol returns a point for each line that is not empty or a comment, nil for invalid points which are
+// reported in pointErrors. lines holds the line number of each point.
+func parseLineProtocol(body io.Reader, precision string, now time.Time) (points []models.Point, lines []int, pointErrors []model.WritePointError, err error) {
+	if !influxdb.ValidPrecision(precision) {
+		return nil, nil, nil, errors.New("Invalid param precision, use one of n, u, ms, s, m, h")
+	}
+	scanner := bufio.NewScanner(body)
+	scanner.Buffer(make([]byte, 0, 64*1024), maxWriteBodySize)
+	lines = []int{}
+	for number := 1; scanner.Scan(); number++ {
+		line := bytes.TrimSpace(scanner.Bytes())
+		if len(line) == 0 || line[0] == '#' {
+			continue
+		}
+		point, err := influxdb.ParseLine(string(line), precision, now)
+		if err != nil {
+			pointErrors = append(pointErrors, model.WritePointError{Index: len(points), Line: number, Error: err.Error()})
+		}
+		points = append(points, point)
+		lines = append(lines, number)
+	}
+	return points, lines, pointErrors, scanner.Err()
+}
+
+func sortWritePointErrors(pointErrors []model.WritePointError) {
+	sort.Slice(pointErrors, func(i, j int) bool {
+		return pointErrors[i].Index < pointErrors[j].Index
+	})
+}
@@ -0,0 +1,87 @@
+/*
+ *    Copyright 2021 InfAI (CC SES)
+ *
+ *    Licensed under the Apache License, Version 2.0 (the "License");
+ *    you may not use this file except in compliance with the License.
+ *    You may obtain a copy of the License at
+ *
+ *        http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *    Unless required by applicable law or agreed to in writing, software
+ *    distributed under the License is distributed on an "AS IS" BASIS,
+ *    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *    See the License for the specific language governing permissions and
+ *    limitations under the License.
+ */
+
+package api
+
+import (
+	"encoding/json"
+	"github.com/SENERGY-Platform/influx-wrapper/pkg/api/model"
+	"github.com/SENERGY-Platform/influx-wrapper/pkg/configuration"
+	influxdb "github.com/SENERGY-Platform/influx-wrapper/pkg/influx"
+	"github.com/SENERGY-Platform/influx-wrapper/pkg/tests/services"
+	"github.com/julienschmidt/httprouter"
+	influxLib "github.com/orourkedd/influxdb1-client"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestWriteSlowBody(t *testing.T) {
+	influxClientMock := services.NewClientMock()
+	influxClientMock.SetQueryResponse(&influxLib.Response{Results: []influxLib.Result{{}}}, nil)
+	written := ""
+	influxClientMock.SetWriteHandler(func(db string, lines []byte) error {
+		written += string(lines)
+		return nil
+	})
+	config := &configuration.ConfigStruct{WriteTimeout: "1m"}
+	router := httprouter.New()
+	WriteEndpoint(router, config, influxdb.NewInfluxWithClient(config, &influxClientMock))
+
+	// the body takes longer than the read timeout of the server
+	server := httptest.NewUnstartedServer(router)
+	server.Config.ReadTimeout = 100 * time.Millisecond
+	server.Config.WriteTimeout = 100 * time.Millisecond
+	server.Start()
+	defer server.Close()
+
+	bodyReader, bodyWriter := io.Pipe()
+	go func() {
+		for i := 0; i < 3; i++ {
+			time.Sleep(100 * time.Millisecond)
+			_, err := bodyWriter.Write([]byte("m1 c1=" + strings.Repeat("1", i+1) + " 1609459200000000000\n"))
+			if err != nil {
+				bodyWriter.CloseWithError(err)
+				return
+			}
+		}
+		bodyWriter.Close()
+	}()
+	request, err := http.NewRequest("POST", server.URL+"/write", bodyReader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	request.Header.Set(userHeader, "db")
+	response, err := http.DefaultClient.Do(request)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer response.Body.Close()
+	if response.StatusCode != http.StatusOK {
+		t.Fatal(response.StatusCode)
+	}
+	result := model.WriteResponse{}
+	err = json.NewDecoder(response.Body).Decode(&result)
+	if err != nil || result.Written != 3 || result.Failed != 0 {
+		t.Error(result, err)
+	}
+	if strings.Count(written, "\n") != 3 {
+		t.Error(written)
+	}
+}
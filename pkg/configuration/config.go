@@ -28,11 +28,22 @@ import (
 )
 
 type ConfigStruct struct {
-	ApiPort      string `json:"api_port"`
-	InfluxDbUrl  string `json:"influx_db_url"`
-	InfluxDbUser string `json:"influx_db_user"`
-	InfluxDbPw   string `json:"influx_db_pw"`
-	Debug        bool   `json:"debug"`
+	ApiPort                    string `json:"api_port"`
+	InfluxDbUrl                string `json:"influx_db_url"`
+	InfluxDbUser               string `json:"influx_db_user"`
+	InfluxDbPw                 string `json:"influx_db_pw"`
+	QueryTimeout               string `json:"query_timeout"`
+	StreamTimeout              string `json:"stream_timeout"`
+	WriteTimeout               string `json:"write_timeout"`
+	ReadyCache                 string `json:"ready_cache"`
+	ShutdownDelay              string `json:"shutdown_delay"`
+	WriteBatchSize             int64  `json:"write_batch_size"`
+	WriteMaxPoints             int64  `json:"write_max_points"`
+	AuditLog                   string `json:"audit_log"`
+	RetentionPolicyMaxCount    int64  `json:"retention_policy_max_count"`
+	RetentionPolicyMinDuration string `json:"retention_policy_min_duration"`
+	RetentionPolicyMaxDuration string `json:"retention_policy_max_duration"`
+	Debug                      bool   `json:"debug"`
 }
 
 type Config = *ConfigStruct
@@ -0,0 +1,155 @@
+/*
+ *    Copyright 2021 InfAI (CC SES)
+ *
+ *    Licensed under the Apache License, Version 2.0 (the "License");
+ *    you may not use this file except in compliance with the License.
+ *    You may obtain a copy of the License at
+ *
+ *        http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *    Unless required by applicable law or agreed to in writing, software
+ *    distributed under the License is distributed on an "AS IS" BASIS,
+ *    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *    See the License for the specific language governing permissions and
+ *    limitations under the License.
+ */
+
+package influx
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	influxLib "github.com/orourkedd/influxdb1-client"
+	"io"
+	"net/http"
+	"path"
+	"strconv"
+	"time"
+)
+
+// client extends the influx client with chunked queries, which are read chunk by chunk instead of being merged into a
+// single response, and writes and pings with a context.
+type client struct {
+	*influxLib.Client
+	config     influxLib.Config
+	httpClient *http.Client
+}
+
+func newClient(config influxLib.Config) (*client, error) {
+	influxClient, err := influxLib.NewClient(config)
+	if err != nil {
+		return nil, err
+	}
+	return &client{
+		Client:     influxClient,
+		config:     config,
+		httpClient: &http.Client{Timeout: config.Timeout},
+	}, nil
+}
+
+// PingContext checks if InfluxDB is reachable, the request is cancelled with ctx. Returns the latency and the version
+// of InfluxDB. Responses without success status, e.g. of a proxy in front of InfluxDB, are returned as error.
+func (this *client) PingContext(ctx context.Context) (time.Duration, string, error) {
+	now := time.Now()
+	u := this.config.URL
+	u.Path = path.Join(u.Path, "ping")
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
+	if err != nil {
+		return 0, "", err
+	}
+	if this.config.Username != "" {
+		req.SetBasicAuth(this.config.Username, this.config.Password)
+	}
+	resp, err := this.httpClient.Do(req)
+	if err != nil {
+		return 0, "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return 0, "", fmt.Errorf("ping received status code %d from server", resp.StatusCode)
+	}
+	return time.Since(now), resp.Header.Get("X-Influxdb-Version"), nil
+}
+
+// QueryChunked returns the body of a chunked response, which can be read with influxLib.NewChunkedResponse
+func (this *client) QueryChunked(ctx context.Context, query influxLib.Query) (io.ReadCloser, error) {
+	u := this.config.URL
+	u.Path = path.Join(u.Path, "query")
+	values := u.Query()
+	values.Set("q", query.Command)
+	values.Set("db", query.Database)
+	if query.RetentionPolicy != "" {
+		values.Set("rp", query.RetentionPolicy)
+	}
+	values.Set("chunked", "true")
+	if query.ChunkSize > 0 {
+		values.Set("chunk_size", strconv.Itoa(query.ChunkSize))
+	}
+	u.RawQuery = values.Encode()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, u.String(), nil)
+	if err != nil {
+		return nil, err
+	}
+	if this.config.Username != "" {
+		req.SetBasicAuth(this.config.Username, this.config.Password)
+	}
+	resp, err := this.httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		defer resp.Body.Close()
+		response, err := influxLib.NewChunkedResponse(resp.Body).NextResponse()
+		if err != nil {
+			return nil, err
+		}
+		if response != nil && response.Error() != nil {
+			return nil, response.Error()
+		}
+		return nil, fmt.Errorf("received status code %d from server", resp.StatusCode)
+	}
+	return resp.Body, nil
+}
+
+// Write sends points in line protocol with nanosecond precision. Points rejected by InfluxDB are reported as
+// WriteRejectedError.
+func (this *client) Write(ctx context.Context, db string, lines []byte) error {
+	u := this.config.URL
+	u.Path = path.Join(u.Path, "write")
+	values := u.Query()
+	values.Set("db", db)
+	values.Set("precision", "ns")
+	u.RawQuery = values.Encode()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, u.String(), bytes.NewReader(lines))
+	if err != nil {
+		return err
+	}
+	if this.config.Username != "" {
+		req.SetBasicAuth(this.config.Username, this.config.Password)
+	}
+	resp, err := this.httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusNoContent || resp.StatusCode == http.StatusOK {
+		return nil
+	}
+	response := struct {
+		Error string `json:"error"`
+	}{}
+	err = json.NewDecoder(resp.Body).Decode(&response)
+	if err != nil || response.Error == "" {
+		response.Error = fmt.Sprintf("received status code %d from server", resp.StatusCode)
+	}
+	if resp.StatusCode == http.StatusBadRequest {
+		return WriteRejectedError{Message: response.Error}
+	}
+	return errors.New(response.Error)
+}
@@ -0,0 +1,121 @@
+/*
+ *    Copyright 2021 InfAI (CC SES)
+ *
+ *    Licensed under the Apache License, Version 2.0 (the "License");
+ *    you may not use this file except in compliance with the License.
+ *    You may obtain a copy of the License at
+ *
+ *        http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *    Unless required by applicable law or agreed to in writing, software
+ *    distributed under the License is distributed on an "AS IS" BASIS,
+ *    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *    See the License for the specific language governing permissions and
+ *    limitations under the License.
+ */
+
+package influx
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"github.com/SENERGY-Platform/influx-wrapper/pkg/api/model"
+	influxLib "github.com/orourkedd/influxdb1-client"
+)
+
+// DeletePoints deletes the points of measurement selected by request. Returns the executed statement, which is also
+// returned if the execution failed.
+func (this *Influx) DeletePoints(ctx context.Context, db string, measurement string, request model.DeleteRequest) (query string, err error) {
+	condition, err := buildDeleteCondition(request)
+	if err != nil {
+		return "", err
+	}
+	statement := DeleteStatement{Source: measurement, Condition: condition}
+	query, err = statement.Render()
+	if err != nil {
+		return "", err
+	}
+	_, err = this.ExecuteQuery(ctx, db, query)
+	return query, err
+}
+
+// CountPoints returns the number of points of measurement selected by request. Points are counted per field, so the
+// count of each series is the maximum count of its fields. It is exact if all points of a series have the same fields.
+func (this *Influx) CountPoints(ctx context.Context, db string, measurement string, request model.DeleteRequest) (count int64, err error) {
+	statement := SelectStatement{
+		Fields:  []Field{{Expr: &Call{Name: "count", Args: []Expr{&Wildcard{}}}}},
+		Sources: []string{measurement},
+		GroupBy: []Expr{&Wildcard{}},
+	}
+	statement.Condition, err = buildDeleteCondition(request)
+	if err != nil {
+		return 0, err
+	}
+	query, err := statement.Render()
+	if err != nil {
+		return 0, err
+	}
+	response, err := this.ExecuteQuery(ctx, db, query)
+	if err != nil {
+		return 0, err
+	}
+	if len(response.Results) != 1 {
+		return 0, ErrNULL
+	}
+	return countSeries(response.Results[0])
+}
+
+func countSeries(result influxLib.Result) (count int64, err error) {
+	for _, series := range result.Series {
+		var max int64
+		for _, row := range series.Values {
+			for _, value := range row[1:] {
+				var fieldCount int64
+				switch v := value.(type) {
+				case json.Number:
+					fieldCount, err = v.Int64()
+					if err != nil {
+						return 0, err
+					}
+				case float64:
+					fieldCount = int64(v)
+				case nil:
+					continue
+				default:
+					return 0, errors.New("unexpected response type (not a number)")
+				}
+				if fieldCount > max {
+					max = fieldCount
+				}
+			}
+		}
+		count += max
+	}
+	return count, nil
+}
+
+// buildDeleteCondition returns nil if all points are selected. InfluxDB does not resolve the type of references in
+// DELETE statements, so tags are referenced without type.
+func buildDeleteCondition(request model.DeleteRequest) (condition Expr, err error) {
+	conditions := []Expr{}
+	for _, filter := range request.TagFilters {
+		expr, err := buildTagFilter(filter, false)
+		if err != nil {
+			return nil, err
+		}
+		expr.(*BinaryExpr).LHS = &VarRef{Name: filter.Key}
+		conditions = append(conditions, expr)
+	}
+	if request.Time != nil {
+		timeCondition, err := buildTimeCondition(*request.Time)
+		if err != nil {
+			return nil, err
+		}
+		conditions = append(conditions, timeCondition)
+	}
+	if len(conditions) == 0 && !request.All {
+		return nil, ErrInvalidStatement
+	}
+	return and(conditions...), nil
+}
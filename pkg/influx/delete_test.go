@@ -0,0 +1,84 @@
+/*
+ *    Copyright 2021 InfAI (CC SES)
+ *
+ *    Licensed under the Apache License, Version 2.0 (the "License");
+ *    you may not use this file except in compliance with the License.
+ *    You may obtain a copy of the License at
+ *
+ *        http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *    Unless required by applicable law or agreed to in writing, software
+ *    distributed under the License is distributed on an "AS IS" BASIS,
+ *    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *    See the License for the specific language governing permissions and
+ *    limitations under the License.
+ */
+
+package influx
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/SENERGY-Platform/influx-wrapper/pkg/api/model"
+	"github.com/SENERGY-Platform/influx-wrapper/pkg/configuration"
+	"github.com/SENERGY-Platform/influx-wrapper/pkg/tests/services"
+	influxLib "github.com/orourkedd/influxdb1-client"
+	"github.com/orourkedd/influxdb1-client/models"
+	"testing"
+)
+
+func TestDelete(t *testing.T) {
+	influxClientMock := services.NewClientMock()
+	influxClient := Influx{
+		config: &configuration.ConfigStruct{},
+		client: &influxClientMock,
+	}
+	start := "2021-01-01T00:00:00Z"
+	end := "2021-01-02T00:00:00Z"
+	request := model.DeleteRequest{
+		Time: &model.QueriesRequestElementTime{Start: &start, End: &end},
+		TagFilters: []model.QueriesRequestElementTagFilter{
+			{Key: "device", Type: "=", Value: "d'1"},
+			{Key: "service", Type: "=~", Value: "^s"},
+		},
+	}
+
+	t.Run("DeletePoints", func(t *testing.T) {
+		influxClientMock.SetQueryResponse(&influxLib.Response{Results: []influxLib.Result{{}}}, nil)
+		query, err := influxClient.DeletePoints(context.Background(), "db", "m1", request)
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		expect := "DELETE FROM \"m1\" WHERE \"device\" = 'd\\'1' AND \"service\" =~ /^s/ AND \"time\" > '2021-01-01T00:00:00Z' AND \"time\" < '2021-01-02T00:00:00Z'"
+		if query != expect {
+			t.Error("\nexpect\n", expect, "\nactual\n", query)
+		}
+	})
+	t.Run("DeletePoints all", func(t *testing.T) {
+		query, err := influxClient.DeletePoints(context.Background(), "db", "m1", model.DeleteRequest{All: true})
+		if err != nil || query != "DELETE FROM \"m1\"" {
+			t.Error(query, err)
+		}
+		_, err = influxClient.DeletePoints(context.Background(), "db", "m1", model.DeleteRequest{})
+		if err != ErrInvalidStatement {
+			t.Error("expected ErrInvalidStatement, got", err)
+		}
+	})
+	t.Run("CountPoints", func(t *testing.T) {
+		influxClientMock.SetQueryResponse(&influxLib.Response{
+			Results: []influxLib.Result{{Series: []models.Row{
+				{Name: "m1", Tags: map[string]string{"device": "d1"}, Columns: []string{"time", "count_c1", "count_c2"}, Values: [][]interface{}{{"1970-01-01T00:00:00Z", json.Number("3"), json.Number("5")}}},
+				{Name: "m1", Tags: map[string]string{"device": "d2"}, Columns: []string{"time", "count_c1", "count_c2"}, Values: [][]interface{}{{"1970-01-01T00:00:00Z", json.Number("2"), nil}}},
+			}}},
+		}, nil)
+		count, err := influxClient.CountPoints(context.Background(), "db", "m1", request)
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		if count != 7 {
+			t.Error(count)
+		}
+	})
+}
@@ -17,9 +17,13 @@
 package influx
 
 import (
+	"context"
 	"github.com/SENERGY-Platform/influx-wrapper/pkg/configuration"
 	influxLib "github.com/orourkedd/influxdb1-client"
+	"log"
 	"net/url"
+	"strings"
+	"time"
 )
 
 func NewInflux(config configuration.Config) (influx *Influx, err error) {
@@ -32,7 +36,7 @@ func NewInflux(config configuration.Config) (influx *Influx, err error) {
 		Username: config.InfluxDbUser,
 		Password: config.InfluxDbPw,
 	}
-	client, err := influxLib.NewClient(influxConfig)
+	client, err := newClient(influxConfig)
 	if err != nil {
 		return influx, err
 	}
@@ -40,40 +44,210 @@ func NewInflux(config configuration.Config) (influx *Influx, err error) {
 	return &Influx{config: config, client: influxClient}, nil
 }
 
-func (this *Influx) GetLatestValue(db string, pair RequestElement) (timeValuePair TimeValuePair, err error) {
-	timeValuePairs, err := this.GetLatestValues(db, []RequestElement{pair})
+// NewInfluxWithClient uses client to communicate with InfluxDB, e.g. a mock in tests
+func NewInfluxWithClient(config configuration.Config, client Client) *Influx {
+	return &Influx{config: config, client: client}
+}
+
+// Ping checks if InfluxDB is reachable. Returns the latency and the version of InfluxDB.
+func (this *Influx) Ping(ctx context.Context) (latency time.Duration, version string, err error) {
+	latency, version, err = this.client.PingContext(ctx)
+	if err != nil {
+		if ctx.Err() == context.DeadlineExceeded {
+			return 0, "", ErrTimeout
+		}
+		if ctx.Err() != nil {
+			return 0, "", ctx.Err()
+		}
+		log.Println(err.Error())
+		return latency, version, ErrInfluxConnection
+	}
+	return latency, version, nil
+}
+
+func (this *Influx) GetLatestValue(ctx context.Context, db string, pair RequestElement) (timeValuePair TimeValuePair, err error) {
+	timeValuePairs, err := this.GetLatestValues(ctx, db, []RequestElement{pair})
 	if err != nil {
 		return timeValuePair, err
 	}
 	return timeValuePairs[0], err
 }
 
-func (this *Influx) GetLatestValues(db string, pairs []RequestElement) (timeValuePairs []TimeValuePair, err error) {
-	set := transformMeasurementColumnPairs(pairs)
-
-	query := generateQuery(set) + " ORDER BY \"time\" DESC LIMIT 1"
-	responseP, err := this.ExecuteQuery(db, query)
+// GetLatestValues returns the latest value of each element. Elements with a limit only return their newest value.
+// Fails if any element fails.
+func (this *Influx) GetLatestValues(ctx context.Context, db string, pairs []RequestElement) (timeValuePairs []TimeValuePair, err error) {
+	values, errs, err := this.GetLastValues(ctx, db, pairs)
 	if err != nil {
 		return timeValuePairs, err
 	}
+	for _, err := range errs {
+		if err != nil {
+			return timeValuePairs, err
+		}
+	}
+	for _, list := range values {
+		if len(list) == 0 {
+			timeValuePairs = append(timeValuePairs, TimeValuePair{Time: nil, Value: nil})
+			continue
+		}
+		timeValuePairs = append(timeValuePairs, list[0])
+	}
+	return
+}
+
+// GetLastValues returns the values of each element, newest first. Elements without limit, maxAge and groupTags share
+// a single statement and always get exactly one value, which might be nil. All other elements get their own statement
+// and up to limit values per tag combination, or none if there is no value within maxAge.
+// errs holds the error of each failed element. If the shared statement fails, its elements are retried one by one, so
+// only the failing ones report an error. err is only set if all elements failed or InfluxDB is not usable at all,
+// e.g. because the database does not exist.
+func (this *Influx) GetLastValues(ctx context.Context, db string, pairs []RequestElement) (values [][]TimeValuePair, errs []error, err error) {
+	values, errs, err = this.getLastValues(ctx, db, pairs)
+	if err != nil {
+		return nil, nil, err
+	}
+	latest := 0
+	for _, pair := range pairs {
+		if pair.latest() {
+			latest++
+		}
+	}
+	if latest > 1 {
+		for i, pair := range pairs {
+			if !pair.latest() || errs[i] == nil {
+				continue
+			}
+			list, pairErrs, err := this.getLastValues(ctx, db, []RequestElement{pair})
+			if err != nil {
+				return nil, nil, err
+			}
+			values[i], errs[i] = list[0], pairErrs[0]
+		}
+	}
+	failed := 0
+	for _, err := range errs {
+		if err != nil {
+			failed++
+		}
+	}
+	if failed > 0 && failed == len(pairs) {
+		return nil, nil, errs[0]
+	}
+	return values, errs, nil
+}
+
+// getLastValues executes the statements of all elements at once. Errors of single statements are assigned to their
+// elements, all elements sharing the latest values statement fail together.
+func (this *Influx) getLastValues(ctx context.Context, db string, pairs []RequestElement) (values [][]TimeValuePair, errs []error, err error) {
+	latest := []RequestElement{}
+	statements := []string{}
+	for _, pair := range pairs {
+		if pair.latest() {
+			latest = append(latest, pair)
+		}
+	}
+	var set uniqueMeasurementsColumns
+	if len(latest) > 0 {
+		set = transformMeasurementColumnPairs(latest)
+		statement, err := buildLatestValuesQuery(set)
+		if err != nil {
+			return nil, nil, err
+		}
+		statement.OrderBy = OrderDesc
+		statement.Limit = 1
+		query, err := statement.Render()
+		if err != nil {
+			return nil, nil, err
+		}
+		statements = append(statements, query)
+	}
+	for _, pair := range pairs {
+		if pair.latest() {
+			continue
+		}
+		statement, err := buildLastValuesQuery(pair)
+		if err != nil {
+			return nil, nil, err
+		}
+		query, err := statement.Render()
+		if err != nil {
+			return nil, nil, err
+		}
+		statements = append(statements, query)
+	}
+	values = make([][]TimeValuePair, len(pairs))
+	errs = make([]error, len(pairs))
+	if len(statements) == 0 {
+		return values, errs, nil
+	}
+
+	responseP, err := this.ExecuteQuery(ctx, db, strings.Join(statements, "; "))
+	if responseP == nil || responseP.Err != nil || len(responseP.Results) != len(statements) {
+		if err == nil {
+			err = ErrNULL
+		}
+		return nil, nil, err
+	}
+
+	var latestValues []TimeValuePair
+	var latestErr error
+	resultIndex := 0
+	if len(latest) > 0 {
+		latestErr = this.resultError(responseP.Results[0])
+		if latestErr == nil {
+			latestValues, latestErr = latestValuesFromResult(latest, set, responseP.Results[0])
+		}
+		if latestErr == ErrNotFound {
+			return nil, nil, latestErr
+		}
+		resultIndex++
+	}
+	for i, pair := range pairs {
+		if pair.latest() {
+			if latestErr != nil {
+				errs[i] = latestErr
+				continue
+			}
+			values[i] = latestValues[:1]
+			latestValues = latestValues[1:]
+			continue
+		}
+		result := responseP.Results[resultIndex]
+		resultIndex++
+		errs[i] = this.resultError(result)
+		if errs[i] == ErrNotFound {
+			return nil, nil, errs[i]
+		}
+		if errs[i] == nil {
+			values[i], errs[i] = lastValuesFromResult(pair, result)
+		}
+	}
+	return values, errs, nil
+}
 
-	if len(responseP.Results) != 1 {
-		return timeValuePairs, ErrNULL
+// resultError maps the error of a single statement
+func (this *Influx) resultError(result influxLib.Result) error {
+	if result.Err == nil {
+		return nil
 	}
+	return this.responseError(result.Err)
+}
 
+// latestValuesFromResult maps the rows of a statement built by buildLatestValuesQuery to the requested pairs
+func latestValuesFromResult(pairs []RequestElement, set uniqueMeasurementsColumns, result influxLib.Result) (timeValuePairs []TimeValuePair, err error) {
 	numExpectedColumns := 0
 	for key := range set.Columns {
 		numExpectedColumns += len(set.Columns[key])
 	}
 
-	for i := range responseP.Results[0].Series {
-		if len(responseP.Results[0].Series[i].Values) != 1 || len(responseP.Results[0].Series[i].Values[0]) != numExpectedColumns {
+	for i := range result.Series {
+		if len(result.Series[i].Values) != 1 || len(result.Series[i].Values[0]) != numExpectedColumns {
 			return timeValuePairs, ErrNULL
 		}
 	}
 
 	for _, pair := range pairs {
-		seriesIndex, err := findSeriesIndex(pair.Measurement, responseP.Results[0].Series)
+		seriesIndex, err := findSeriesIndex(pair.Measurement, result.Series)
 		if err != nil {
 			if err == ErrNotFound {
 				timeValuePairs = append(timeValuePairs, TimeValuePair{
@@ -84,10 +258,10 @@ func (this *Influx) GetLatestValues(db string, pairs []RequestElement) (timeValu
 			}
 			return timeValuePairs, err
 		}
-		columnIndex, err := findColumnIndex(getColumnName(pair), responseP.Results[0].Series[seriesIndex])
+		columnIndex, err := findColumnIndex(getColumnName(pair), result.Series[seriesIndex])
 		if err != nil {
 			if err == ErrNotFound {
-				time := responseP.Results[0].Series[seriesIndex].Values[0][0].(string)
+				time := result.Series[seriesIndex].Values[0][0].(string)
 				timeValuePairs = append(timeValuePairs, TimeValuePair{
 					Time:  &time,
 					Value: nil,
@@ -97,12 +271,46 @@ func (this *Influx) GetLatestValues(db string, pairs []RequestElement) (timeValu
 			return timeValuePairs, err
 		}
 
-		time := responseP.Results[0].Series[seriesIndex].Values[0][0].(string)
+		time := result.Series[seriesIndex].Values[0][0].(string)
 		timeValuePairs = append(timeValuePairs, TimeValuePair{
 			Time:  &time,
-			Value: responseP.Results[0].Series[seriesIndex].Values[0][columnIndex],
+			Value: result.Series[seriesIndex].Values[0][columnIndex],
 		})
 	}
 
 	return
 }
+
+// lastValuesFromResult reads the rows of a statement built by buildLastValuesQuery. Series of different tag
+// combinations are concatenated in the order returned by influx.
+func lastValuesFromResult(pair RequestElement, result influxLib.Result) (timeValuePairs []TimeValuePair, err error) {
+	timeValuePairs = []TimeValuePair{}
+	for _, series := range result.Series {
+		columnIndex, err := findColumnIndex(getColumnName(pair), series)
+		if err != nil {
+			return timeValuePairs, ErrNULL
+		}
+		var tags map[string]string
+		if len(pair.GroupTags) > 0 {
+			tags = make(map[string]string, len(pair.GroupTags))
+			for _, tag := range pair.GroupTags {
+				tags[tag] = series.Tags[tag]
+			}
+		}
+		for _, row := range series.Values {
+			if len(row) != len(series.Columns) {
+				return timeValuePairs, ErrNULL
+			}
+			time, ok := row[0].(string)
+			if !ok {
+				return timeValuePairs, ErrNULL
+			}
+			timeValuePairs = append(timeValuePairs, TimeValuePair{
+				Time:  &time,
+				Value: row[columnIndex],
+				Tags:  tags,
+			})
+		}
+	}
+	return timeValuePairs, nil
+}
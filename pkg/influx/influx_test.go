@@ -17,12 +17,16 @@
 package influx
 
 import (
+	"context"
 	"errors"
 	"github.com/SENERGY-Platform/influx-wrapper/pkg/configuration"
 	"github.com/SENERGY-Platform/influx-wrapper/pkg/tests/services"
 	influxLib "github.com/orourkedd/influxdb1-client"
 	"github.com/orourkedd/influxdb1-client/models"
+	"net"
+	"strings"
 	"testing"
+	"time"
 )
 
 func TestInflux(t *testing.T) {
@@ -52,6 +56,45 @@ func TestInflux(t *testing.T) {
 		})
 	})
 
+	t.Run("Ping", func(t *testing.T) {
+		influxClientMock := services.NewClientMock()
+		influxClient := Influx{
+			config: &configuration.ConfigStruct{},
+			client: &influxClientMock,
+		}
+		t.Run("reachable", func(t *testing.T) {
+			influxClientMock.SetPingResponse(time.Millisecond, "1.8.10", nil)
+			latency, version, err := influxClient.Ping(context.Background())
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			if latency != time.Millisecond || version != "1.8.10" {
+				t.Error(latency, version)
+			}
+		})
+		t.Run("unreachable", func(t *testing.T) {
+			influxClientMock.SetPingResponse(0, "", errors.New("connection refused"))
+			_, _, err := influxClient.Ping(context.Background())
+			if err != ErrInfluxConnection {
+				t.Error("expected ErrInfluxConnection, got", err)
+			}
+		})
+		t.Run("timeout", func(t *testing.T) {
+			influxClientMock.SetPingHandler(func(ctx context.Context) (time.Duration, string, error) {
+				<-ctx.Done()
+				return 0, "", ctx.Err()
+			})
+			defer influxClientMock.SetPingHandler(nil)
+			ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+			defer cancel()
+			_, _, err := influxClient.Ping(ctx)
+			if err != ErrTimeout {
+				t.Error("expected ErrTimeout, got", err)
+			}
+		})
+	})
+
 	t.Run("GetLatestValues", func(t *testing.T) {
 		influxClientMock := services.NewClientMock()
 		influxClient := Influx{
@@ -88,7 +131,7 @@ func TestInflux(t *testing.T) {
 				ColumnName:  "c1",
 			}
 			t.Run("normal", func(t *testing.T) {
-				actual, err := influxClient.GetLatestValue(db, measurementColumnPair)
+				actual, err := influxClient.GetLatestValue(context.Background(), db, measurementColumnPair)
 				expect := TimeValuePair{
 					Time:  &t1,
 					Value: 1,
@@ -104,14 +147,14 @@ func TestInflux(t *testing.T) {
 			t.Run("error", func(t *testing.T) {
 				testErr := errors.New("random err")
 				influxClientMock.SetQueryResponse(&influxLib.Response{}, testErr)
-				_, err := influxClient.GetLatestValue(db, measurementColumnPair)
+				_, err := influxClient.GetLatestValue(context.Background(), db, measurementColumnPair)
 				if err != testErr {
 					t.Fail()
 				}
 			})
 			t.Run("no result", func(t *testing.T) {
 				influxClientMock.SetQueryResponse(&influxLib.Response{}, nil)
-				_, err := influxClient.GetLatestValue(db, measurementColumnPair)
+				_, err := influxClient.GetLatestValue(context.Background(), db, measurementColumnPair)
 				if err != ErrNULL {
 					t.Fail()
 				}
@@ -130,7 +173,7 @@ func TestInflux(t *testing.T) {
 						},
 					},
 				}, nil)
-				_, err := influxClient.GetLatestValue(db, measurementColumnPair)
+				_, err := influxClient.GetLatestValue(context.Background(), db, measurementColumnPair)
 				if err != ErrNULL {
 					t.Fail()
 				}
@@ -180,7 +223,7 @@ func TestInflux(t *testing.T) {
 						Value: v2,
 					},
 				}
-				actual, err := influxClient.GetLatestValues(db, measurementColumnPairs)
+				actual, err := influxClient.GetLatestValues(context.Background(), db, measurementColumnPairs)
 				if err != nil {
 					t.Fail()
 					return
@@ -217,7 +260,7 @@ func TestInflux(t *testing.T) {
 				}
 				math1 := "+5"
 				math2 := "-5"
-				actual, err := influxClient.GetLatestValues(db, []RequestElement{
+				actual, err := influxClient.GetLatestValues(context.Background(), db, []RequestElement{
 					{
 						Measurement: "m1",
 						ColumnName:  "c1",
@@ -263,7 +306,7 @@ func TestInflux(t *testing.T) {
 						Value: nil,
 					},
 				}
-				actual, err := influxClient.GetLatestValues(db, measurementColumnPairs)
+				actual, err := influxClient.GetLatestValues(context.Background(), db, measurementColumnPairs)
 				if err != nil {
 					t.Fail()
 					return
@@ -305,7 +348,7 @@ func TestInflux(t *testing.T) {
 						Value: nil,
 					},
 				}
-				actual, err := influxClient.GetLatestValues(db, measurementColumnPairs)
+				actual, err := influxClient.GetLatestValues(context.Background(), db, measurementColumnPairs)
 				if err != nil {
 					t.Fail()
 					return
@@ -316,6 +359,260 @@ func TestInflux(t *testing.T) {
 			})
 		})
 	})
+
+	t.Run("GetLastValues", func(t *testing.T) {
+		influxClientMock := services.NewClientMock()
+		influxClient := Influx{
+			config: &configuration.ConfigStruct{},
+			client: &influxClientMock,
+		}
+
+		t1 := "2000-01-01T00:00:00.000Z"
+		t2 := "2000-01-02T00:00:00.000Z"
+		limit := 2
+		maxAge := "1h"
+		pairs := []RequestElement{
+			{Measurement: "m1", ColumnName: "c1", Limit: &limit},
+			{Measurement: "m1", ColumnName: "c2"},
+			{Measurement: "m2", ColumnName: "c1", MaxAge: &maxAge},
+		}
+		influxClientMock.SetQueryResponse(&influxLib.Response{
+			Results: []influxLib.Result{
+				{
+					Series: []models.Row{
+						{
+							Name:    "m1",
+							Columns: []string{"time", "c2"},
+							Values:  [][]interface{}{{t2, 3}},
+						},
+					},
+				},
+				{
+					Series: []models.Row{
+						{
+							Name:    "m1",
+							Columns: []string{"time", "c1"},
+							Values:  [][]interface{}{{t2, 2}, {t1, 1}},
+						},
+					},
+				},
+				{},
+			},
+		}, nil)
+		t.Run("normal", func(t *testing.T) {
+			actual, errs, err := influxClient.GetLastValues(context.Background(), "db", pairs)
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			for _, err := range errs {
+				if err != nil {
+					t.Error(err)
+				}
+			}
+			expected := [][]TimeValuePair{
+				{{Time: &t2, Value: 2}, {Time: &t1, Value: 1}},
+				{{Time: &t2, Value: 3}},
+				{},
+			}
+			if len(actual) != len(expected) {
+				t.Error(actual)
+				return
+			}
+			for i := range expected {
+				if !timeValuePairListEquals(expected[i], actual[i]) {
+					t.Error(i, actual[i])
+				}
+			}
+		})
+		t.Run("latest", func(t *testing.T) {
+			actual, err := influxClient.GetLatestValues(context.Background(), "db", pairs)
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			expected := []TimeValuePair{{Time: &t2, Value: 2}, {Time: &t2, Value: 3}, {Time: nil, Value: nil}}
+			if !timeValuePairListEquals(expected, actual) {
+				t.Error(actual)
+			}
+		})
+		t.Run("groupTags", func(t *testing.T) {
+			influxClientMock.SetQueryResponse(&influxLib.Response{
+				Results: []influxLib.Result{
+					{
+						Series: []models.Row{
+							{
+								Name:    "m1",
+								Tags:    map[string]string{"device": "d1"},
+								Columns: []string{"time", "c1"},
+								Values:  [][]interface{}{{t1, 1}},
+							},
+							{
+								Name:    "m1",
+								Tags:    map[string]string{"device": "d2"},
+								Columns: []string{"time", "c1"},
+								Values:  [][]interface{}{{t2, 2}},
+							},
+						},
+					},
+				},
+			}, nil)
+			actual, _, err := influxClient.GetLastValues(context.Background(), "db", []RequestElement{
+				{Measurement: "m1", ColumnName: "c1", GroupTags: []string{"device"}},
+			})
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			expected := []TimeValuePair{{Time: &t1, Value: 1}, {Time: &t2, Value: 2}}
+			if len(actual) != 1 || !timeValuePairListEquals(expected, actual[0]) {
+				t.Error(actual)
+				return
+			}
+			if actual[0][0].Tags["device"] != "d1" || actual[0][1].Tags["device"] != "d2" {
+				t.Error(actual[0][0].Tags, actual[0][1].Tags)
+			}
+		})
+		t.Run("missing result", func(t *testing.T) {
+			influxClientMock.SetQueryResponse(&influxLib.Response{}, nil)
+			_, _, err := influxClient.GetLastValues(context.Background(), "db", pairs)
+			if err != ErrNULL {
+				t.Error("expected ErrNULL, got", err)
+			}
+		})
+		// perStatement answers each statement of a query on its own and counts the executed queries
+		queries := 0
+		perStatement := func(result func(statement string) influxLib.Result) {
+			queries = 0
+			influxClientMock.SetQueryHandler(func(q influxLib.Query) (*influxLib.Response, error) {
+				queries++
+				response := &influxLib.Response{}
+				for _, statement := range strings.Split(q.Command, "; ") {
+					response.Results = append(response.Results, result(statement))
+				}
+				return response, nil
+			})
+		}
+		t.Run("isolated errors", func(t *testing.T) {
+			testErr := errors.New("invalid operation")
+			perStatement(func(statement string) influxLib.Result {
+				if strings.Contains(statement, "m2") {
+					return influxLib.Result{Err: testErr}
+				}
+				return influxLib.Result{Series: []models.Row{{Name: "m1", Columns: []string{"time", "c1"}, Values: [][]interface{}{{t1, 1}}}}}
+			})
+			defer influxClientMock.SetQueryHandler(nil)
+			actual, errs, err := influxClient.GetLastValues(context.Background(), "db", []RequestElement{
+				{Measurement: "m1", ColumnName: "c1"},
+				{Measurement: "m2", ColumnName: "c1"},
+				{Measurement: "m1", ColumnName: "c1", Limit: &limit},
+				{Measurement: "m2", ColumnName: "c1", Limit: &limit},
+			})
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			if errs[0] != nil || errs[1] == nil || errs[1].Error() != testErr.Error() || errs[2] != nil || errs[3] == nil {
+				t.Error(errs)
+				return
+			}
+			expected := []TimeValuePair{{Time: &t1, Value: 1}}
+			if !timeValuePairListEquals(expected, actual[0]) || !timeValuePairListEquals(expected, actual[2]) {
+				t.Error(actual)
+			}
+			// only the two elements of the failed latest values statement are retried
+			if queries != 3 {
+				t.Error("expected 3 queries, got", queries)
+			}
+		})
+		t.Run("all failed", func(t *testing.T) {
+			perStatement(func(statement string) influxLib.Result {
+				return influxLib.Result{Err: errors.New("invalid operation")}
+			})
+			defer influxClientMock.SetQueryHandler(nil)
+			_, _, err := influxClient.GetLastValues(context.Background(), "db", pairs)
+			if err == nil || err.Error() != "invalid operation" {
+				t.Error("expected error, got", err)
+			}
+		})
+		t.Run("database not found", func(t *testing.T) {
+			perStatement(func(statement string) influxLib.Result {
+				return influxLib.Result{Err: errors.New("database not found: db")}
+			})
+			defer influxClientMock.SetQueryHandler(nil)
+			_, _, err := influxClient.GetLastValues(context.Background(), "db", []RequestElement{
+				{Measurement: "m1", ColumnName: "c1"},
+				{Measurement: "m2", ColumnName: "c1"},
+			})
+			if err != ErrNotFound {
+				t.Error("expected ErrNotFound, got", err)
+			}
+			if queries != 1 {
+				t.Error("expected 1 query, got", queries)
+			}
+		})
+		t.Run("connection error", func(t *testing.T) {
+			influxClientMock.SetQueryResponse(nil, &net.OpError{Op: "dial", Err: errors.New("connection refused")})
+			_, _, err := influxClient.GetLastValues(context.Background(), "db", pairs)
+			if err != ErrInfluxConnection {
+				t.Error("expected ErrInfluxConnection, got", err)
+			}
+		})
+	})
+
+	t.Run("schema", func(t *testing.T) {
+		influxClientMock := services.NewClientMock()
+		influxClient := Influx{
+			config: &configuration.ConfigStruct{},
+			client: &influxClientMock,
+		}
+		t.Run("GetMeasurements", func(t *testing.T) {
+			influxClientMock.SetQueryResponse(&influxLib.Response{
+				Results: []influxLib.Result{{Series: []models.Row{{Name: "measurements", Columns: []string{"name"}, Values: [][]interface{}{{"m1"}, {"m2"}}}}}},
+			}, nil)
+			actual, err := influxClient.GetMeasurements(context.Background(), "db", "", 10, 0)
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			if len(actual) != 2 || actual[0] != "m1" || actual[1] != "m2" {
+				t.Error(actual)
+			}
+		})
+		t.Run("GetFieldKeys", func(t *testing.T) {
+			influxClientMock.SetQueryResponse(&influxLib.Response{
+				Results: []influxLib.Result{{Series: []models.Row{{Name: "m1", Columns: []string{"fieldKey", "fieldType"}, Values: [][]interface{}{{"c1", "float"}, {"c2", "string"}}}}}},
+			}, nil)
+			actual, err := influxClient.GetFieldKeys(context.Background(), "db", "m1", 10, 0)
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			if len(actual) != 2 || actual[0].Key != "c1" || actual[0].Type != "float" || actual[1].Key != "c2" || actual[1].Type != "string" {
+				t.Error(actual)
+			}
+		})
+		t.Run("GetTagKeys empty", func(t *testing.T) {
+			influxClientMock.SetQueryResponse(&influxLib.Response{Results: []influxLib.Result{{}}}, nil)
+			actual, err := influxClient.GetTagKeys(context.Background(), "db", "m1", 10, 0)
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			if actual == nil || len(actual) != 0 {
+				t.Error(actual)
+			}
+		})
+		t.Run("unexpected row", func(t *testing.T) {
+			influxClientMock.SetQueryResponse(&influxLib.Response{
+				Results: []influxLib.Result{{Series: []models.Row{{Name: "m1", Columns: []string{"tagKey"}, Values: [][]interface{}{{1}}}}}},
+			}, nil)
+			_, err := influxClient.GetTagKeys(context.Background(), "db", "m1", 10, 0)
+			if err == nil {
+				t.Error("expected error")
+			}
+		})
+	})
 }
 
 func timeValuePairEquals(p1 TimeValuePair, p2 TimeValuePair) bool {
@@ -0,0 +1,646 @@
+/*
+ *    Copyright 2021 InfAI (CC SES)
+ *
+ *    Licensed under the Apache License, Version 2.0 (the "License");
+ *    you may not use this file except in compliance with the License.
+ *    You may obtain a copy of the License at
+ *
+ *        http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *    Unless required by applicable law or agreed to in writing, software
+ *    distributed under the License is distributed on an "AS IS" BASIS,
+ *    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *    See the License for the specific language governing permissions and
+ *    limitations under the License.
+ */
+
+package influx
+
+import (
+	"errors"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// The types in this file model the subset of InfluxQL used by this service. Statements are rendered with all
+// identifiers and literals escaped, so user supplied values can never change the structure of a statement.
+
+var ErrInvalidStatement = errors.New("invalid statement")
+
+var identReplacer = strings.NewReplacer("\n", `\n`, `\`, `\\`, `"`, `\"`)
+var stringReplacer = strings.NewReplacer("\n", `\n`, `\`, `\\`, `'`, `\'`)
+var functionNameMatcher = regexp.MustCompile("^[a-zA-Z_][a-zA-Z0-9_]*$")
+var durationMatcher = regexp.MustCompile("^\\d+(ns|u|µ|ms|s|m|h|d|w)$")
+
+// QuoteIdent returns name as a double quoted InfluxQL identifier.
+func QuoteIdent(name string) string {
+	return "\"" + identReplacer.Replace(name) + "\""
+}
+
+// QuoteString returns s as a single quoted InfluxQL string literal.
+func QuoteString(s string) string {
+	return "'" + stringReplacer.Replace(s) + "'"
+}
+
+type SelectStatement struct {
+	Fields    []Field
+	Sources   []string
+	Condition Expr
+	GroupBy   []Expr
+	Fill      *Fill
+	OrderBy   SortOrder
+	Limit     int
+	Offset    int
+	// Timezone is the IANA name of the zone used for GROUP BY time() intervals and returned timestamps
+	Timezone string
+	// RetentionPolicy qualifies all sources, the default retention policy is used if empty
+	RetentionPolicy string
+}
+
+type Field struct {
+	Expr  Expr
+	Alias string
+}
+
+// Fill sets how empty intervals of a GROUP BY time() query are filled. Value is only used with FillNumber.
+type Fill struct {
+	Option FillOption
+	Value  float64
+}
+
+type FillOption string
+
+const (
+	FillNull     FillOption = "null"
+	FillNone     FillOption = "none"
+	FillPrevious FillOption = "previous"
+	FillLinear   FillOption = "linear"
+	FillNumber   FillOption = "number"
+)
+
+func (fill *Fill) render(b *strings.Builder) error {
+	b.WriteString(" fill(")
+	switch fill.Option {
+	case FillNull, FillNone, FillPrevious, FillLinear:
+		b.WriteString(string(fill.Option))
+	case FillNumber:
+		err := (&NumberLiteral{Val: fill.Value}).render(b)
+		if err != nil {
+			return err
+		}
+	default:
+		return ErrInvalidStatement
+	}
+	b.WriteString(")")
+	return nil
+}
+
+type SortOrder string
+
+const (
+	Unordered SortOrder = ""
+	OrderAsc  SortOrder = "ASC"
+	OrderDesc SortOrder = "DESC"
+)
+
+func (stmt *SelectStatement) Render() (string, error) {
+	b := &strings.Builder{}
+	b.WriteString("SELECT ")
+	for i, field := range stmt.Fields {
+		if i > 0 {
+			b.WriteString(", ")
+		}
+		err := field.Expr.render(b)
+		if err != nil {
+			return "", err
+		}
+		if field.Alias != "" {
+			b.WriteString(" AS ")
+			b.WriteString(QuoteIdent(field.Alias))
+		}
+	}
+	b.WriteString(" FROM ")
+	for i, source := range stmt.Sources {
+		if i > 0 {
+			b.WriteString(", ")
+		}
+		if stmt.RetentionPolicy != "" {
+			b.WriteString(QuoteIdent(stmt.RetentionPolicy) + ".")
+		}
+		b.WriteString(QuoteIdent(source))
+	}
+	if stmt.Condition != nil {
+		b.WriteString(" WHERE ")
+		err := stmt.Condition.render(b)
+		if err != nil {
+			return "", err
+		}
+	}
+	if len(stmt.GroupBy) > 0 {
+		b.WriteString(" GROUP BY ")
+		for i, dimension := range stmt.GroupBy {
+			if i > 0 {
+				b.WriteString(", ")
+			}
+			err := dimension.render(b)
+			if err != nil {
+				return "", err
+			}
+		}
+	}
+	if stmt.Fill != nil {
+		if len(stmt.GroupBy) == 0 {
+			return "", ErrInvalidStatement
+		}
+		err := stmt.Fill.render(b)
+		if err != nil {
+			return "", err
+		}
+	}
+	switch stmt.OrderBy {
+	case Unordered:
+	case OrderAsc, OrderDesc:
+		b.WriteString(" ORDER BY time " + string(stmt.OrderBy))
+	default:
+		return "", ErrInvalidStatement
+	}
+	if stmt.Limit < 0 {
+		return "", ErrInvalidStatement
+	}
+	if stmt.Limit > 0 {
+		b.WriteString(" LIMIT " + strconv.Itoa(stmt.Limit))
+	}
+	if stmt.Offset < 0 {
+		return "", ErrInvalidStatement
+	}
+	if stmt.Offset > 0 {
+		b.WriteString(" OFFSET " + strconv.Itoa(stmt.Offset))
+	}
+	if stmt.Timezone != "" {
+		b.WriteString(" tz(" + QuoteString(stmt.Timezone) + ")")
+	}
+	return b.String(), nil
+}
+
+// RenderStatements renders all statements separated by semicolons.
+func RenderStatements(statements []SelectStatement) (query string, err error) {
+	parts := make([]string, len(statements))
+	for i := range statements {
+		parts[i], err = statements[i].Render()
+		if err != nil {
+			return "", err
+		}
+	}
+	return strings.Join(parts, "; "), nil
+}
+
+// ShowStatement lists schema information of the database.
+type ShowStatement struct {
+	Object ShowObject
+	// Source is the measurement of SHOW FIELD KEYS, SHOW TAG KEYS and SHOW TAG VALUES. Required for SHOW TAG VALUES.
+	Source    string
+	With      *ShowWith
+	Condition Expr
+	Limit     int
+	Offset    int
+}
+
+type ShowObject string
+
+const (
+	ShowMeasurements ShowObject = "MEASUREMENTS"
+	ShowFieldKeys    ShowObject = "FIELD KEYS"
+	ShowTagKeys      ShowObject = "TAG KEYS"
+	ShowTagValues    ShowObject = "TAG VALUES"
+	// ShowTagValuesCardinality counts the tag values instead of listing them. It can not be limited.
+	ShowTagValuesCardinality ShowObject = "TAG VALUES EXACT CARDINALITY"
+	// ShowRetentionPolicies lists the retention policies of the database the statement is executed on. It can not be
+	// combined with any other option.
+	ShowRetentionPolicies ShowObject = "RETENTION POLICIES"
+)
+
+// ShowWith filters SHOW MEASUREMENTS by measurement name or SHOW TAG VALUES by tag key. Either Regex or Names is
+// used, a single name is rendered as equality, multiple names as IN list.
+type ShowWith struct {
+	Names []string
+	Regex *RegexLiteral
+}
+
+func (stmt *ShowStatement) Render() (string, error) {
+	b := &strings.Builder{}
+	b.WriteString("SHOW " + string(stmt.Object))
+	withKeyword := ""
+	switch stmt.Object {
+	case ShowMeasurements:
+		if stmt.Source != "" {
+			return "", ErrInvalidStatement
+		}
+		withKeyword = "MEASUREMENT"
+	case ShowFieldKeys, ShowTagKeys:
+	case ShowTagValues, ShowTagValuesCardinality:
+		if stmt.Source == "" || stmt.With == nil {
+			return "", ErrInvalidStatement
+		}
+		if stmt.Object == ShowTagValuesCardinality && (stmt.Limit != 0 || stmt.Offset != 0) {
+			return "", ErrInvalidStatement
+		}
+		withKeyword = "KEY"
+	case ShowRetentionPolicies:
+		if stmt.Source != "" || stmt.Condition != nil || stmt.Limit != 0 || stmt.Offset != 0 {
+			return "", ErrInvalidStatement
+		}
+	default:
+		return "", ErrInvalidStatement
+	}
+	if stmt.Source != "" {
+		b.WriteString(" FROM " + QuoteIdent(stmt.Source))
+	}
+	if stmt.With != nil {
+		if withKeyword == "" {
+			return "", ErrInvalidStatement
+		}
+		b.WriteString(" WITH " + withKeyword)
+		switch {
+		case stmt.With.Regex != nil:
+			b.WriteString(" =~ ")
+			err := stmt.With.Regex.render(b)
+			if err != nil {
+				return "", err
+			}
+		case len(stmt.With.Names) == 1:
+			b.WriteString(" = " + QuoteIdent(stmt.With.Names[0]))
+		case len(stmt.With.Names) > 1:
+			b.WriteString(" IN (")
+			for i, name := range stmt.With.Names {
+				if i > 0 {
+					b.WriteString(", ")
+				}
+				b.WriteString(QuoteIdent(name))
+			}
+			b.WriteString(")")
+		default:
+			return "", ErrInvalidStatement
+		}
+	}
+	if stmt.Condition != nil {
+		if stmt.Object == ShowFieldKeys {
+			return "", ErrInvalidStatement
+		}
+		b.WriteString(" WHERE ")
+		err := stmt.Condition.render(b)
+		if err != nil {
+			return "", err
+		}
+	}
+	if stmt.Limit < 0 || stmt.Offset < 0 {
+		return "", ErrInvalidStatement
+	}
+	if stmt.Limit > 0 {
+		b.WriteString(" LIMIT " + strconv.Itoa(stmt.Limit))
+	}
+	if stmt.Offset > 0 {
+		b.WriteString(" OFFSET " + strconv.Itoa(stmt.Offset))
+	}
+	return b.String(), nil
+}
+
+// DeleteStatement deletes the points of a measurement matching Condition, all points if Condition is nil.
+type DeleteStatement struct {
+	Source    string
+	Condition Expr
+}
+
+func (stmt *DeleteStatement) Render() (string, error) {
+	if stmt.Source == "" {
+		return "", ErrInvalidStatement
+	}
+	b := &strings.Builder{}
+	b.WriteString("DELETE FROM " + QuoteIdent(stmt.Source))
+	if stmt.Condition != nil {
+		b.WriteString(" WHERE ")
+		err := stmt.Condition.render(b)
+		if err != nil {
+			return "", err
+		}
+	}
+	return b.String(), nil
+}
+
+// RetentionPolicyStatement creates the retention policy Name on Database, or alters it if Alter is set. Duration is a
+// time interval or INF, created policies require a Duration and always have a replication factor of 1. Options which
+// are empty are not altered.
+type RetentionPolicyStatement struct {
+	Alter         bool
+	Name          string
+	Database      string
+	Duration      string
+	ShardDuration string
+	Default       bool
+}
+
+func (stmt *RetentionPolicyStatement) Render() (string, error) {
+	if stmt.Name == "" || stmt.Database == "" {
+		return "", ErrInvalidStatement
+	}
+	if stmt.Duration != "" && stmt.Duration != "INF" && !durationMatcher.MatchString(stmt.Duration) {
+		return "", ErrInvalidStatement
+	}
+	if stmt.ShardDuration != "" && !durationMatcher.MatchString(stmt.ShardDuration) {
+		return "", ErrInvalidStatement
+	}
+	b := &strings.Builder{}
+	if stmt.Alter {
+		if stmt.Duration == "" && stmt.ShardDuration == "" && !stmt.Default {
+			return "", ErrInvalidStatement
+		}
+		b.WriteString("ALTER")
+	} else {
+		if stmt.Duration == "" {
+			return "", ErrInvalidStatement
+		}
+		b.WriteString("CREATE")
+	}
+	b.WriteString(" RETENTION POLICY " + QuoteIdent(stmt.Name) + " ON " + QuoteIdent(stmt.Database))
+	if stmt.Duration != "" {
+		b.WriteString(" DURATION " + stmt.Duration)
+	}
+	if !stmt.Alter {
+		b.WriteString(" REPLICATION 1")
+	}
+	if stmt.ShardDuration != "" {
+		b.WriteString(" SHARD DURATION " + stmt.ShardDuration)
+	}
+	if stmt.Default {
+		b.WriteString(" DEFAULT")
+	}
+	return b.String(), nil
+}
+
+type Expr interface {
+	render(b *strings.Builder) error
+}
+
+// VarRef references a field or tag. Type may be set to "tag" or "field" to resolve ambiguous names.
+type VarRef struct {
+	Name string
+	Type string
+}
+
+func (e *VarRef) render(b *strings.Builder) error {
+	b.WriteString(QuoteIdent(e.Name))
+	switch e.Type {
+	case "":
+	case "tag", "field":
+		b.WriteString("::" + e.Type)
+	default:
+		return ErrInvalidStatement
+	}
+	return nil
+}
+
+type Call struct {
+	Name string
+	Args []Expr
+}
+
+func (e *Call) render(b *strings.Builder) error {
+	if !functionNameMatcher.MatchString(e.Name) {
+		return ErrInvalidStatement
+	}
+	b.WriteString(e.Name)
+	b.WriteString("(")
+	for i, arg := range e.Args {
+		if i > 0 {
+			b.WriteString(", ")
+		}
+		err := arg.render(b)
+		if err != nil {
+			return err
+		}
+	}
+	b.WriteString(")")
+	return nil
+}
+
+type BinaryExpr struct {
+	Op  string
+	LHS Expr
+	RHS Expr
+}
+
+var operatorPrecedence = map[string]int{
+	"OR":  1,
+	"AND": 2,
+	"=":   4, "!=": 4, "<>": 4, "<": 4, "<=": 4, ">": 4, ">=": 4, "=~": 4, "!~": 4,
+	"+": 5, "-": 5,
+	"*": 6, "/": 6,
+}
+
+func (e *BinaryExpr) render(b *strings.Builder) error {
+	precedence, ok := operatorPrecedence[e.Op]
+	if !ok || e.LHS == nil || e.RHS == nil {
+		return ErrInvalidStatement
+	}
+	err := renderOperand(b, e.LHS, precedence, false)
+	if err != nil {
+		return err
+	}
+	if precedence >= operatorPrecedence["+"] {
+		b.WriteString(e.Op)
+	} else {
+		b.WriteString(" " + e.Op + " ")
+	}
+	return renderOperand(b, e.RHS, precedence, true)
+}
+
+func renderOperand(b *strings.Builder, operand Expr, parentPrecedence int, rhs bool) error {
+	needsParens := false
+	switch o := operand.(type) {
+	case *BinaryExpr:
+		precedence := operatorPrecedence[o.Op]
+		// AND and OR are associative, other operators are left associative
+		associative := o.Op == "AND" || o.Op == "OR"
+		needsParens = precedence < parentPrecedence || (rhs && precedence == parentPrecedence && !associative)
+	case *NumberLiteral:
+		// avoids rendering "--", which starts a comment
+		needsParens = o.Val < 0
+	}
+	if !needsParens {
+		return operand.render(b)
+	}
+	b.WriteString("(")
+	err := operand.render(b)
+	b.WriteString(")")
+	return err
+}
+
+type ParenExpr struct {
+	Expr Expr
+}
+
+func (e *ParenExpr) render(b *strings.Builder) error {
+	b.WriteString("(")
+	err := e.Expr.render(b)
+	b.WriteString(")")
+	return err
+}
+
+type StringLiteral struct {
+	Val string
+}
+
+func (e *StringLiteral) render(b *strings.Builder) error {
+	b.WriteString(QuoteString(e.Val))
+	return nil
+}
+
+type NumberLiteral struct {
+	Val float64
+}
+
+func (e *NumberLiteral) render(b *strings.Builder) error {
+	if math.IsNaN(e.Val) || math.IsInf(e.Val, 0) {
+		return ErrInvalidStatement
+	}
+	b.WriteString(strconv.FormatFloat(e.Val, 'f', -1, 64))
+	return nil
+}
+
+type BooleanLiteral struct {
+	Val bool
+}
+
+func (e *BooleanLiteral) render(b *strings.Builder) error {
+	b.WriteString(strconv.FormatBool(e.Val))
+	return nil
+}
+
+// DurationLiteral holds an InfluxQL duration like 5m or 1d.
+type DurationLiteral struct {
+	Val string
+}
+
+func (e *DurationLiteral) render(b *strings.Builder) error {
+	if !durationMatcher.MatchString(e.Val) {
+		return ErrInvalidStatement
+	}
+	b.WriteString(e.Val)
+	return nil
+}
+
+// Wildcard is the * of count(*) or GROUP BY *.
+type Wildcard struct{}
+
+func (e *Wildcard) render(b *strings.Builder) error {
+	b.WriteString("*")
+	return nil
+}
+
+// RegexLiteral holds a regular expression, which is rendered between slashes.
+type RegexLiteral struct {
+	Val string
+}
+
+func (e *RegexLiteral) render(b *strings.Builder) error {
+	_, err := regexp.Compile(e.Val)
+	if err != nil {
+		return ErrInvalidStatement
+	}
+	b.WriteString("/")
+	// InfluxDB reads \/ as a slash and passes any other backslash through, so \\ followed by a slash would end in an
+	// escaped slash. Escaped backslashes are written as \x5c, which matches the same, so no backslash is ever followed
+	// by a slash that is not escaped on purpose.
+	for i := 0; i < len(e.Val); i++ {
+		switch e.Val[i] {
+		case '\\':
+			if i+1 >= len(e.Val) || e.Val[i+1] == '\\' {
+				b.WriteString("\\x5c")
+			} else if e.Val[i+1] == '/' {
+				b.WriteString("\\/")
+			} else if e.Val[i+1] == '\n' {
+				b.WriteString("\\n")
+			} else {
+				b.WriteByte(e.Val[i])
+				b.WriteByte(e.Val[i+1])
+			}
+			i++
+		case '/':
+			b.WriteString("\\/")
+		case '\n':
+			b.WriteString("\\n")
+		default:
+			b.WriteByte(e.Val[i])
+		}
+	}
+	b.WriteString("/")
+	return nil
+}
+
+type TimeLiteral struct {
+	Val time.Time
+}
+
+func (e *TimeLiteral) render(b *strings.Builder) error {
+	b.WriteString(QuoteString(e.Val.UTC().Format(time.RFC3339Nano)))
+	return nil
+}
+
+// and combines all non nil expressions with AND. Returns nil if there is nothing to combine.
+func and(exprs ...Expr) (combined Expr) {
+	for _, expr := range exprs {
+		if expr == nil {
+			continue
+		}
+		if combined == nil {
+			combined = expr
+		} else {
+			combined = &BinaryExpr{Op: "AND", LHS: combined, RHS: expr}
+		}
+	}
+	return combined
+}
+
+func now() Expr {
+	return &Call{Name: "now"}
+}
+
+func timeRef() Expr {
+	return &VarRef{Name: "time"}
+}
+
+// literal converts a value decoded from JSON to an InfluxQL literal.
+func literal(value interface{}) (Expr, error) {
+	switch v := value.(type) {
+	case string:
+		return &StringLiteral{Val: v}, nil
+	case float64:
+		return &NumberLiteral{Val: v}, nil
+	case int:
+		return &NumberLiteral{Val: float64(v)}, nil
+	case bool:
+		return &BooleanLiteral{Val: v}, nil
+	default:
+		return nil, ErrInvalidStatement
+	}
+}
+
+// mathExpr applies a basic math operation like "+5" or "/2,5" to lhs.
+func mathExpr(lhs Expr, operation string) (Expr, error) {
+	if len(operation) < 2 || !strings.ContainsRune("+-*/", rune(operation[0])) {
+		return nil, ErrInvalidMath
+	}
+	operand := strings.Replace(operation[1:], ",", ".", 1)
+	for _, r := range operand {
+		if (r < '0' || r > '9') && r != '.' {
+			return nil, ErrInvalidMath
+		}
+	}
+	value, err := strconv.ParseFloat(operand, 64)
+	if err != nil {
+		return nil, ErrInvalidMath
+	}
+	return &BinaryExpr{Op: operation[:1], LHS: lhs, RHS: &NumberLiteral{Val: value}}, nil
+}
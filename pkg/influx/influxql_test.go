@@ -0,0 +1,429 @@
+/*
+ *    Copyright 2021 InfAI (CC SES)
+ *
+ *    Licensed under the Apache License, Version 2.0 (the "License");
+ *    you may not use this file except in compliance with the License.
+ *    You may obtain a copy of the License at
+ *
+ *        http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *    Unless required by applicable law or agreed to in writing, software
+ *    distributed under the License is distributed on an "AS IS" BASIS,
+ *    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *    See the License for the specific language governing permissions and
+ *    limitations under the License.
+ */
+
+package influx
+
+import (
+	"errors"
+	"github.com/SENERGY-Platform/influx-wrapper/pkg/api/model"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestInfluxQL(t *testing.T) {
+	t.Run("QuoteIdent", func(t *testing.T) {
+		actual := QuoteIdent("a\"b\\c\nd")
+		expect := "\"a\\\"b\\\\c\\nd\""
+		if actual != expect {
+			t.Error("expect", expect, "actual", actual)
+		}
+	})
+	t.Run("QuoteString", func(t *testing.T) {
+		actual := QuoteString("a'b\\c\nd")
+		expect := "'a\\'b\\\\c\\nd'"
+		if actual != expect {
+			t.Error("expect", expect, "actual", actual)
+		}
+	})
+	t.Run("render", func(t *testing.T) {
+		statement := SelectStatement{
+			Fields: []Field{
+				{Expr: &Call{Name: "mean", Args: []Expr{&VarRef{Name: "c1"}}}},
+				{Expr: &BinaryExpr{Op: "*", LHS: &VarRef{Name: "c2"}, RHS: &NumberLiteral{Val: -2}}, Alias: "c2*-2"},
+			},
+			Sources: []string{"m1"},
+			Condition: &BinaryExpr{Op: "AND",
+				LHS: &BinaryExpr{Op: "OR",
+					LHS: &BinaryExpr{Op: "=", LHS: &VarRef{Name: "c1"}, RHS: &StringLiteral{Val: "x"}},
+					RHS: &BinaryExpr{Op: ">", LHS: &VarRef{Name: "c2"}, RHS: &BooleanLiteral{Val: true}},
+				},
+				RHS: &BinaryExpr{Op: ">", LHS: timeRef(), RHS: &TimeLiteral{Val: time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC)}},
+			},
+			GroupBy: []Expr{&Call{Name: "time", Args: []Expr{&DurationLiteral{Val: "1h"}}}},
+			OrderBy: OrderAsc,
+			Limit:   10,
+		}
+		actual, err := statement.Render()
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		expect := "SELECT mean(\"c1\"), \"c2\"*(-2) AS \"c2*-2\" FROM \"m1\" WHERE (\"c1\" = 'x' OR \"c2\" > true) AND \"time\" > '2021-01-01T00:00:00Z' GROUP BY time(1h) ORDER BY time ASC LIMIT 10"
+		if actual != expect {
+			t.Error("\nexpect\n", expect, "\nactual\n", actual)
+		}
+	})
+	t.Run("invalid", func(t *testing.T) {
+		statements := map[string]SelectStatement{
+			"function name": {Fields: []Field{{Expr: &Call{Name: "mean(\"x\")"}}}},
+			"operator":      {Fields: []Field{{Expr: &BinaryExpr{Op: ";", LHS: &VarRef{Name: "a"}, RHS: &VarRef{Name: "b"}}}}},
+			"duration":      {GroupBy: []Expr{&Call{Name: "time", Args: []Expr{&DurationLiteral{Val: "1h); DROP DATABASE x"}}}}},
+			"order":         {OrderBy: "; DROP DATABASE x"},
+			"limit":         {Limit: -1},
+			"fill":          {GroupBy: []Expr{&VarRef{Name: "a"}}, Fill: &Fill{Option: "none); DROP DATABASE x"}},
+			"fill no group": {Fill: &Fill{Option: FillNone}},
+			"var ref type":  {GroupBy: []Expr{&VarRef{Name: "a", Type: "tag; DROP DATABASE x"}}},
+		}
+		for name, statement := range statements {
+			_, err := statement.Render()
+			if err != ErrInvalidStatement {
+				t.Error(name, "expected ErrInvalidStatement, got", err)
+			}
+		}
+	})
+	t.Run("regex", func(t *testing.T) {
+		for pattern, expect := range map[string]string{
+			"^a/b$":      "/^a\\/b$/",
+			"^a\\/b$":    "/^a\\/b$/",
+			"\\d+\\.\\d": "/\\d+\\.\\d/",
+			"a\nb":       "/a\\nb/",
+			"x\\\\":      "/x\\x5c/",
+			"[\\\\/]":    "/[\\x5c\\/]/",
+		} {
+			b := &strings.Builder{}
+			err := (&RegexLiteral{Val: pattern}).render(b)
+			if err != nil || b.String() != expect {
+				t.Error(pattern, err, "expect", expect, "actual", b.String())
+			}
+		}
+		err := (&RegexLiteral{Val: "(unclosed"}).render(&strings.Builder{})
+		if err != ErrInvalidStatement {
+			t.Error("expected ErrInvalidStatement, got", err)
+		}
+	})
+	t.Run("ShowStatement", func(t *testing.T) {
+		statements := map[string]ShowStatement{
+			"SHOW MEASUREMENTS WITH MEASUREMENT =~ /^dev\\.\\/x/ LIMIT 11 OFFSET 20": {Object: ShowMeasurements, With: &ShowWith{Regex: &RegexLiteral{Val: PrefixRegex("dev./x")}}, Limit: 11, Offset: 20},
+			"SHOW FIELD KEYS FROM \"m\\\"1\"":                                        {Object: ShowFieldKeys, Source: "m\"1"},
+			"SHOW TAG KEYS FROM \"m1\" LIMIT 5":                                      {Object: ShowTagKeys, Source: "m1", Limit: 5},
+			"SHOW TAG VALUES FROM \"m1\" WITH KEY = \"k1\"":                          {Object: ShowTagValues, Source: "m1", With: &ShowWith{Names: []string{"k1"}}},
+			"SHOW TAG VALUES FROM \"m1\" WITH KEY IN (\"k1\", \"k2\")":               {Object: ShowTagValues, Source: "m1", With: &ShowWith{Names: []string{"k1", "k2"}}},
+			"SHOW RETENTION POLICIES":                                                {Object: ShowRetentionPolicies},
+		}
+		for expect, statement := range statements {
+			actual, err := statement.Render()
+			if err != nil {
+				t.Error(expect, err)
+				continue
+			}
+			if actual != expect {
+				t.Error("\nexpect\n", expect, "\nactual\n", actual)
+			}
+		}
+		invalid := map[string]ShowStatement{
+			"object":                    {Object: "DATABASES; DROP DATABASE x"},
+			"measurements source":       {Object: ShowMeasurements, Source: "m1"},
+			"tag values key":            {Object: ShowTagValues, Source: "m1"},
+			"field keys with":           {Object: ShowFieldKeys, With: &ShowWith{Names: []string{"k1"}}},
+			"empty with":                {Object: ShowMeasurements, With: &ShowWith{}},
+			"regex":                     {Object: ShowMeasurements, With: &ShowWith{Regex: &RegexLiteral{Val: "("}}},
+			"limit":                     {Object: ShowMeasurements, Limit: -1},
+			"retention policies source": {Object: ShowRetentionPolicies, Source: "m1"},
+			"retention policies limit":  {Object: ShowRetentionPolicies, Limit: 1},
+			"cardinality limit":         {Object: ShowTagValuesCardinality, Source: "m1", With: &ShowWith{Names: []string{"k1"}}, Limit: 1},
+		}
+		for name, statement := range invalid {
+			_, err := statement.Render()
+			if err != ErrInvalidStatement {
+				t.Error(name, "expected ErrInvalidStatement, got", err)
+			}
+		}
+	})
+	t.Run("RetentionPolicyStatement", func(t *testing.T) {
+		statements := map[string]RetentionPolicyStatement{
+			"CREATE RETENTION POLICY \"raw\" ON \"db\" DURATION 30d REPLICATION 1":                            {Name: "raw", Database: "db", Duration: "30d"},
+			"CREATE RETENTION POLICY \"long\" ON \"db\" DURATION INF REPLICATION 1 SHARD DURATION 1w DEFAULT": {Name: "long", Database: "db", Duration: "INF", ShardDuration: "1w", Default: true},
+			"ALTER RETENTION POLICY \"raw\" ON \"db\" DURATION 60d":                                           {Alter: true, Name: "raw", Database: "db", Duration: "60d"},
+			"ALTER RETENTION POLICY \"r\\\"1\" ON \"db\" DEFAULT":                                             {Alter: true, Name: "r\"1", Database: "db", Default: true},
+		}
+		for expect, statement := range statements {
+			actual, err := statement.Render()
+			if err != nil {
+				t.Error(expect, err)
+				continue
+			}
+			if actual != expect {
+				t.Error("\nexpect\n", expect, "\nactual\n", actual)
+			}
+		}
+		invalid := map[string]RetentionPolicyStatement{
+			"name":            {Database: "db", Duration: "30d"},
+			"database":        {Name: "raw", Duration: "30d"},
+			"create duration": {Name: "raw", Database: "db"},
+			"duration":        {Name: "raw", Database: "db", Duration: "30d; DROP DATABASE db"},
+			"shard duration":  {Name: "raw", Database: "db", Duration: "30d", ShardDuration: "INF"},
+			"alter options":   {Alter: true, Name: "raw", Database: "db"},
+		}
+		for name, statement := range invalid {
+			_, err := statement.Render()
+			if err != ErrInvalidStatement {
+				t.Error(name, "expected ErrInvalidStatement, got", err)
+			}
+		}
+	})
+	t.Run("DeleteStatement", func(t *testing.T) {
+		statement := DeleteStatement{Source: "m1", Condition: &BinaryExpr{Op: "=", LHS: &VarRef{Name: "device"}, RHS: &StringLiteral{Val: "d1"}}}
+		actual, err := statement.Render()
+		expect := "DELETE FROM \"m1\" WHERE \"device\" = 'd1'"
+		if err != nil || actual != expect {
+			t.Error("expect", expect, "actual", actual, err)
+		}
+		if _, err = (&DeleteStatement{}).Render(); err != ErrInvalidStatement {
+			t.Error("expected ErrInvalidStatement, got", err)
+		}
+	})
+	t.Run("mathExpr", func(t *testing.T) {
+		for _, operation := range []string{"", "+", "5", "+5; DROP", "+-5", "%5", "+5e3"} {
+			_, err := mathExpr(&VarRef{Name: "c"}, operation)
+			if err != ErrInvalidMath {
+				t.Error(operation, "expected ErrInvalidMath, got", err)
+			}
+		}
+		expr, err := mathExpr(&VarRef{Name: "c"}, "/2,5")
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		b := &strings.Builder{}
+		err = expr.render(b)
+		if err != nil || b.String() != "\"c\"/2.5" {
+			t.Error(err, b.String())
+		}
+	})
+}
+
+func FuzzGenerateQueries(f *testing.F) {
+	f.Add("m1", "c1", "c2", "value")
+	f.Add("m\"; DROP DATABASE db; --", "c\\", "c'", "' OR 1=1; DROP MEASUREMENT m --")
+	f.Add("\n", "\\\"", "/*", "\\'\n")
+	f.Add("\xdf\"", "\xff", "\xdf", "\xc3'")
+	f.Add("m\\", "c\\\\", "x\\", "x\\\\")
+	f.Fuzz(func(t *testing.T, measurement string, column string, filterColumn string, filterValue string) {
+		last := "1d"
+		elements := []model.QueriesRequestElement{{
+			Measurement: measurement,
+			Columns:     []model.QueriesRequestElementColumn{{Name: column}},
+			Filters:     &[]model.QueriesRequestElementFilter{{Column: filterColumn, Type: "=", Value: filterValue}},
+			Time:        &model.QueriesRequestElementTime{Last: &last},
+		}}
+		query, err := GenerateQueries(elements, model.Desc)
+		if err != nil {
+			t.Fatal(err)
+		}
+		tokens, err := scanInfluxQL(query)
+		if err != nil {
+			t.Fatal(err, query)
+		}
+		if tokens.statements != 1 {
+			t.Fatal("expected exactly one statement", query)
+		}
+		for _, ident := range []string{measurement, column, filterColumn} {
+			if !containsString(tokens.identifiers, ident) {
+				t.Fatal("identifier", ident, "not preserved in", query)
+			}
+		}
+		if !containsString(tokens.strings, filterValue) {
+			t.Fatal("string", filterValue, "not preserved in", query)
+		}
+	})
+}
+
+func FuzzGenerateQueriesTags(f *testing.F) {
+	f.Add("device", "sensor-1", "^sensor-[0-9]+$", "^a")
+	f.Add("d\"; DROP DATABASE db; --", "' OR 1=1 --", "/ OR 1=1; DROP DATABASE db; /", "x")
+	f.Add("\n", "\\'", "\\/\\\\/", "\\\\")
+	f.Add("a", "b", "x\\\\", "; DROP DATABASE \"db\" --")
+	f.Fuzz(func(t *testing.T, key string, value string, pattern string, pattern2 string) {
+		if _, err := regexp.Compile(pattern); err != nil {
+			return
+		}
+		if _, err := regexp.Compile(pattern2); err != nil {
+			return
+		}
+		elements := []model.QueriesRequestElement{{
+			Measurement: "m1",
+			Columns:     []model.QueriesRequestElementColumn{{Name: "c1"}},
+			TagFilters: &[]model.QueriesRequestElementTagFilter{
+				{Key: key, Type: "=", Value: value},
+				{Key: key, Type: "=~", Value: pattern},
+				{Key: "k2", Type: "!~", Value: pattern2},
+			},
+			GroupTags: []string{key},
+		}}
+		query, err := GenerateQueries(elements, model.Desc)
+		if err != nil {
+			t.Fatal(err)
+		}
+		tokens, err := scanInfluxQL(query)
+		if err != nil {
+			t.Fatal(err, query)
+		}
+		if tokens.statements != 1 {
+			t.Fatal("expected exactly one statement", query)
+		}
+		if !containsString(tokens.identifiers, key) {
+			t.Fatal("identifier", key, "not preserved in", query)
+		}
+		if !containsString(tokens.strings, value) {
+			t.Fatal("string", value, "not preserved in", query)
+		}
+		if len(tokens.regexes) != 2 {
+			t.Fatal("expected exactly two regexes", query)
+		}
+		for i, expect := range []string{pattern, pattern2} {
+			if _, err := regexp.Compile(tokens.regexes[i]); err != nil {
+				t.Fatal("regex", expect, "not preserved in", query, err)
+			}
+		}
+	})
+}
+
+func FuzzGenerateQuery(f *testing.F) {
+	f.Add("m1", "c1", "+5")
+	f.Add("m\" ; DROP DATABASE db", "c\" --", "-5 AS x; DROP DATABASE db")
+	f.Fuzz(func(t *testing.T, measurement string, column string, math string) {
+		query, err := generateQuery(transformMeasurementColumnPairs([]RequestElement{{Measurement: measurement, ColumnName: column, Math: &math}}))
+		if err == ErrInvalidMath {
+			return
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		tokens, err := scanInfluxQL(query)
+		if err != nil {
+			t.Fatal(err, query)
+		}
+		if tokens.statements != 1 {
+			t.Fatal("expected exactly one statement", query)
+		}
+	})
+}
+
+type scannedTokens struct {
+	statements  int
+	identifiers []string
+	strings     []string
+	regexes     []string
+}
+
+// scanInfluxQL splits a query into statements and collects all quoted identifiers and strings, following the
+// escaping rules of the InfluxQL scanner. Comments are rejected, since they could hide parts of a statement.
+// Regular expressions are expected right after the operators =~ and !~.
+func scanInfluxQL(query string) (tokens scannedTokens, err error) {
+	tokens.statements = 1
+	chars := []byte(query)
+	for i := 0; i < len(chars); i++ {
+		switch chars[i] {
+		case ';':
+			tokens.statements++
+		case '-':
+			if i+1 < len(chars) && chars[i+1] == '-' {
+				return tokens, errors.New("unexpected comment")
+			}
+		case '/':
+			if i+1 < len(chars) && chars[i+1] == '*' {
+				return tokens, errors.New("unexpected comment")
+			}
+			if i >= 3 && chars[i-1] == ' ' && chars[i-2] == '~' && (chars[i-3] == '=' || chars[i-3] == '!') {
+				var value string
+				value, i, err = scanRegex(chars, i)
+				if err != nil {
+					return tokens, err
+				}
+				tokens.regexes = append(tokens.regexes, value)
+			}
+		case '"', '\'':
+			var value string
+			value, i, err = scanQuoted(chars, i)
+			if err != nil {
+				return tokens, err
+			}
+			if chars[i] == '"' {
+				tokens.identifiers = append(tokens.identifiers, value)
+			} else {
+				tokens.strings = append(tokens.strings, value)
+			}
+		}
+	}
+	return tokens, nil
+}
+
+func scanQuoted(chars []byte, start int) (value string, end int, err error) {
+	quote := chars[start]
+	b := strings.Builder{}
+	for i := start + 1; i < len(chars); i++ {
+		switch chars[i] {
+		case quote:
+			return b.String(), i, nil
+		case '\n':
+			return "", i, errors.New("unescaped newline")
+		case '\\':
+			i++
+			if i >= len(chars) {
+				return "", i, errors.New("unterminated escape")
+			}
+			switch chars[i] {
+			case 'n':
+				b.WriteByte('\n')
+			case '\\', quote:
+				b.WriteByte(chars[i])
+			default:
+				return "", i, errors.New("invalid escape")
+			}
+		default:
+			b.WriteByte(chars[i])
+		}
+	}
+	return "", len(chars), errors.New("unterminated quote")
+}
+
+// scanRegex follows ScanRegex of the InfluxQL scanner: only \/ is unescaped, any other backslash is passed through
+// and the character after it is read on its own, so \\/ ends in an escaped slash.
+func scanRegex(chars []byte, start int) (value string, end int, err error) {
+	b := strings.Builder{}
+	for i := start + 1; i < len(chars); i++ {
+		switch chars[i] {
+		case '/':
+			return b.String(), i, nil
+		case '\n':
+			return "", i, errors.New("unescaped newline")
+		case '\\':
+			if i+1 >= len(chars) {
+				return "", i, errors.New("unterminated escape")
+			}
+			if chars[i+1] == '/' {
+				i++
+				b.WriteByte('/')
+			} else {
+				b.WriteByte('\\')
+			}
+		default:
+			b.WriteByte(chars[i])
+		}
+	}
+	return "", len(chars), errors.New("unterminated regex")
+}
+
+func containsString(list []string, s string) bool {
+	for _, element := range list {
+		if element == s {
+			return true
+		}
+	}
+	return false
+}
@@ -17,9 +17,14 @@
 package influx
 
 import (
+	"context"
 	"errors"
+	"github.com/SENERGY-Platform/influx-wrapper/pkg/api/model"
 	"github.com/SENERGY-Platform/influx-wrapper/pkg/configuration"
 	influxLib "github.com/orourkedd/influxdb1-client"
+	"io"
+	"strconv"
+	"time"
 )
 
 type Influx struct {
@@ -30,12 +35,60 @@ type Influx struct {
 type TimeValuePair struct {
 	Time  *string     `json:"time"`
 	Value interface{} `json:"value"`
+	// Tags holds the tag combination of the value if the request element groups by tags
+	Tags map[string]string `json:"tags,omitempty"`
 }
 
 type RequestElement struct {
 	Measurement string  `json:"measurement"`
 	ColumnName  string  `json:"columnName"`
 	Math        *string `json:"math"`
+	// RetentionPolicy reads the values from this retention policy instead of the default one
+	RetentionPolicy *string `json:"retentionPolicy,omitempty"`
+	// Limit requests up to Limit values, newest first. If set, the values are returned as array.
+	Limit *int `json:"limit,omitempty"`
+	// MaxAge ignores values older than the InfluxQL duration, e.g. 1h
+	MaxAge *string `json:"maxAge,omitempty"`
+	// GroupTags returns the values of each combination of these tag keys, with the tags attached to each value.
+	// If set, the values are returned as array.
+	GroupTags []string `json:"groupTags,omitempty"`
+}
+
+const MaxLastValuesLimit = 10000
+
+func (element *RequestElement) Validate(index int) (errs model.ValidationErrors) {
+	path := "[" + strconv.Itoa(index) + "]"
+	if element.RetentionPolicy != nil && len(*element.RetentionPolicy) == 0 {
+		errs = errs.Add(index, path+".retentionPolicy", "must not be empty")
+	}
+	if element.Limit != nil && (*element.Limit < 1 || *element.Limit > MaxLastValuesLimit) {
+		errs = errs.Add(index, path+".limit", "must be between 1 and "+strconv.Itoa(MaxLastValuesLimit))
+	}
+	if element.MaxAge != nil && !durationMatcher.MatchString(*element.MaxAge) {
+		errs = errs.Add(index, path+".maxAge", "invalid time interval")
+	}
+	knownTags := map[string]struct{}{}
+	for i, tag := range element.GroupTags {
+		if len(tag) == 0 {
+			errs = errs.Add(index, path+".groupTags["+strconv.Itoa(i)+"]", "must not be empty")
+		}
+		if _, ok := knownTags[tag]; ok {
+			errs = errs.Add(index, path+".groupTags["+strconv.Itoa(i)+"]", "duplicate tag")
+		}
+		knownTags[tag] = struct{}{}
+	}
+	return errs
+}
+
+// latest reports if the element only requests the latest value of the default retention policy. These elements share a
+// single statement.
+func (element *RequestElement) latest() bool {
+	return element.Limit == nil && element.MaxAge == nil && len(element.GroupTags) == 0 && element.RetentionPolicy == nil
+}
+
+// Multiple reports if the element may return more than one value, which are returned as array.
+func (element *RequestElement) Multiple() bool {
+	return element.Limit != nil || len(element.GroupTags) > 0
 }
 
 type uniqueMeasurementsColumns struct {
@@ -45,9 +98,27 @@ type uniqueMeasurementsColumns struct {
 }
 
 type Client interface {
-	Query(query influxLib.Query) (*influxLib.Response, error)
+	QueryContext(ctx context.Context, query influxLib.Query) (*influxLib.Response, error)
+	PingContext(ctx context.Context) (time.Duration, string, error)
+	QueryChunked(ctx context.Context, query influxLib.Query) (io.ReadCloser, error)
+	Write(ctx context.Context, db string, lines []byte) error
 }
 
 var ErrInfluxConnection = errors.New("communication with InfluxDB failed")
 var ErrNotFound = errors.New("not found")
 var ErrNULL = errors.New("NULL response")
+var ErrTimeout = errors.New("InfluxDB query timed out")
+var ErrInvalidMath = errors.New("invalid math operation")
+var ErrRetentionPolicyExists = errors.New("retention policy already exists")
+var ErrRetentionPolicyLimit = errors.New("maximum number of retention policies reached")
+var ErrRetentionPolicyProtected = errors.New("retention policy autogen can not be altered")
+
+// WriteRejectedError is returned if InfluxDB rejected written points, e.g. because of a field type conflict. Valid
+// points of the same write might have been written anyway.
+type WriteRejectedError struct {
+	Message string
+}
+
+func (err WriteRejectedError) Error() string {
+	return err.Message
+}
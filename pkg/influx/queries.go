@@ -18,82 +18,290 @@ package influx
 
 import (
 	"github.com/SENERGY-Platform/influx-wrapper/pkg/api/model"
-	"github.com/SENERGY-Platform/influx-wrapper/pkg/util"
 	"strconv"
 	"strings"
+	"time"
 )
 
 func GenerateQueries(elements []model.QueriesRequestElement, timeDirection model.Direction) (query string, err error) {
+	statements, err := BuildQueries(elements, timeDirection)
+	if err != nil {
+		return "", err
+	}
+	return RenderStatements(statements)
+}
+
+// BuildQueries creates one statement per request element. Elements are expected to be validated.
+func BuildQueries(elements []model.QueriesRequestElement, timeDirection model.Direction) (statements []SelectStatement, err error) {
 	for _, element := range elements {
-		if len(query) > 0 {
-			query += "; "
+		statement, err := buildQuery(element, timeDirection)
+		if err != nil {
+			return nil, err
 		}
-		query += "SELECT "
-		for idx, column := range element.Columns {
-			if idx > 0 {
-				query += ", "
-			}
-			if column.GroupType != nil {
-				if strings.HasPrefix(*column.GroupType, "difference") {
-					groupParts := strings.Split(*column.GroupType, "-")
-					query += "difference(" + groupParts[1] + "(\"" + column.Name + "\"))"
-				} else {
-					query += *column.GroupType + "(\"" + column.Name + "\")"
-				}
-			} else {
-				query += "\"" + column.Name + "\""
+		statements = append(statements, statement)
+	}
+	return
+}
+
+func buildQuery(element model.QueriesRequestElement, timeDirection model.Direction) (statement SelectStatement, err error) {
+	statement.Sources = []string{element.Measurement}
+	if element.RetentionPolicy != nil {
+		statement.RetentionPolicy = *element.RetentionPolicy
+	}
+	for _, column := range element.Columns {
+		var field Expr = &VarRef{Name: column.Name}
+		if column.GroupType != nil {
+			field, err = buildGroupType(field, *column.GroupType)
+			if err != nil {
+				return statement, err
 			}
-			if column.Math != nil {
-				query += *column.Math
+		}
+		if column.Math != nil && *column.Math != "" {
+			field, err = mathExpr(field, *column.Math)
+			if err != nil {
+				return statement, err
 			}
 		}
+		statement.Fields = append(statement.Fields, Field{Expr: field})
+	}
 
-		query += " FROM \"" + element.Measurement + "\""
-		if element.Filters != nil || element.Time != nil {
-			query += " WHERE "
-		}
-		if element.Filters != nil {
-			for idx, filter := range *element.Filters {
-				if idx != 0 {
-					query += " AND "
-				}
-				_, valueIsString := filter.Value.(string)
-				query += "\"" + filter.Column + "\" "
-				if filter.Math != nil {
-					query += *filter.Math + " "
-				}
-				query += filter.Type
-				if valueIsString {
-					query += " '" + filter.Value.(string) + "'"
-				} else {
-					value, err := util.String(filter.Value)
-					if err != nil {
-						return "", err
-					}
-					query += " " + value
-				}
+	var conditions []Expr
+	if element.Filters != nil {
+		for _, filter := range *element.Filters {
+			condition, err := buildFilter(filter, false)
+			if err != nil {
+				return statement, err
 			}
+			conditions = append(conditions, condition)
 		}
-		if element.Time != nil {
-			if element.Filters != nil {
-				query += " AND "
+	}
+	if element.TagFilters != nil {
+		for _, filter := range *element.TagFilters {
+			condition, err := buildTagFilter(filter, false)
+			if err != nil {
+				return statement, err
 			}
-			if element.Time.Last != nil {
-				query += " time > now() - " + *element.Time.Last
-			} else if element.Time.Ahead != nil {
-				query += " time > now() AND time < now() + " + *element.Time.Ahead
+			conditions = append(conditions, condition)
+		}
+	}
+	if element.FilterExpression != nil {
+		condition, err := buildFilterExpression(*element.FilterExpression, false)
+		if err != nil {
+			return statement, err
+		}
+		conditions = append(conditions, condition)
+	}
+	if element.Cursor != nil {
+		cursor, err := model.ParseCursor(*element.Cursor)
+		if err != nil {
+			return statement, ErrInvalidStatement
+		}
+		op := "<"
+		if cursor.Direction == model.Asc {
+			op = ">"
+		}
+		if cursor.Skip > 0 {
+			op += "="
+		}
+		conditions = append(conditions, &BinaryExpr{Op: op, LHS: timeRef(), RHS: &TimeLiteral{Val: cursor.Time}})
+	}
+	if element.Time != nil {
+		timeCondition, err := buildTimeCondition(*element.Time)
+		if err != nil {
+			return statement, err
+		}
+		conditions = append(conditions, timeCondition)
+	}
+	statement.Condition = and(conditions...)
+
+	if element.GroupTime != nil {
+		statement.GroupBy = []Expr{&Call{Name: "time", Args: []Expr{&DurationLiteral{Val: *element.GroupTime}}}}
+	} else {
+		statement.OrderBy = SortOrder(strings.ToUpper(string(timeDirection)))
+	}
+	if element.Fill != nil {
+		statement.Fill, err = buildFill(element.Fill)
+		if err != nil {
+			return statement, err
+		}
+	}
+	for _, tag := range element.GroupTags {
+		statement.GroupBy = append(statement.GroupBy, &VarRef{Name: tag})
+	}
+	if element.Limit != nil {
+		statement.Limit = *element.Limit
+	}
+	if element.Offset != nil {
+		statement.Offset = *element.Offset
+	}
+	if element.Cursor != nil {
+		// already parsed above, rows at the cursor time which were already returned are skipped. Cursors can not be
+		// combined with an offset.
+		cursor, _ := model.ParseCursor(*element.Cursor)
+		statement.Offset = cursor.Skip
+	}
+	if element.Timezone != nil {
+		// influx aligns GROUP BY time() intervals to the offset of the zone, including DST transitions, so no
+		// explicit offset is needed
+		statement.Timezone = *element.Timezone
+	}
+	return
+}
+
+// buildGroupType wraps field in the function calls of a group type, e.g. derivative-mean-1s becomes
+// derivative(mean(field), 1s).
+func buildGroupType(field Expr, groupType string) (Expr, error) {
+	parsed, err := model.ParseGroupType(groupType)
+	if err != nil {
+		return nil, ErrInvalidStatement
+	}
+	if parsed.Inner != nil {
+		field = &Call{Name: parsed.Inner.Name, Args: []Expr{field}}
+	}
+	call := &Call{Name: parsed.Function.Name, Args: []Expr{field}}
+	for i, value := range parsed.Params {
+		switch parsed.Function.Params[i].Type {
+		case model.ParamDuration:
+			call.Args = append(call.Args, &DurationLiteral{Val: value})
+		default:
+			number, err := strconv.ParseFloat(value, 64)
+			if err != nil {
+				return nil, ErrInvalidStatement
+			}
+			call.Args = append(call.Args, &NumberLiteral{Val: number})
+		}
+	}
+	return call, nil
+}
+
+// negatedOperators maps each comparison to its negation. InfluxQL has no NOT, negations are pushed down to the
+// comparisons instead.
+var negatedOperators = map[string]string{
+	"=": "!=", "!=": "=", "<>": "=", "<": ">=", ">=": "<", ">": "<=", "<=": ">", "=~": "!~", "!~": "=~",
+}
+
+func negateOperator(op string, negate bool) (string, error) {
+	if !negate {
+		return op, nil
+	}
+	negated, ok := negatedOperators[op]
+	if !ok {
+		return "", ErrInvalidStatement
+	}
+	return negated, nil
+}
+
+func buildFilter(filter model.QueriesRequestElementFilter, negate bool) (Expr, error) {
+	var lhs Expr = &VarRef{Name: filter.Column}
+	var err error
+	if filter.Math != nil && *filter.Math != "" {
+		lhs, err = mathExpr(lhs, *filter.Math)
+		if err != nil {
+			return nil, err
+		}
+	}
+	rhs, err := literal(filter.Value)
+	if err != nil {
+		return nil, err
+	}
+	op, err := negateOperator(filter.Type, negate)
+	if err != nil {
+		return nil, err
+	}
+	return &BinaryExpr{Op: op, LHS: lhs, RHS: rhs}, nil
+}
+
+func buildTagFilter(filter model.QueriesRequestElementTagFilter, negate bool) (Expr, error) {
+	var rhs Expr = &StringLiteral{Val: filter.Value}
+	if filter.Type == "=~" || filter.Type == "!~" {
+		rhs = &RegexLiteral{Val: filter.Value}
+	}
+	op, err := negateOperator(filter.Type, negate)
+	if err != nil {
+		return nil, err
+	}
+	return &BinaryExpr{Op: op, LHS: &VarRef{Name: filter.Key, Type: "tag"}, RHS: rhs}, nil
+}
+
+// buildFilterExpression converts a filter expression tree. If negate is set, the negation of the expression is built
+// by applying De Morgan's laws.
+func buildFilterExpression(expression model.QueriesRequestElementFilterExpression, negate bool) (Expr, error) {
+	switch {
+	case expression.And != nil || expression.Or != nil:
+		children := expression.And
+		op := "AND"
+		if expression.Or != nil {
+			children = expression.Or
+			op = "OR"
+		}
+		if negate {
+			if op == "AND" {
+				op = "OR"
 			} else {
-				query += " time > '" + *element.Time.Start + "' AND time < '" + *element.Time.End + "'"
+				op = "AND"
 			}
 		}
-		if element.GroupTime != nil {
-			query += " GROUP BY time(" + *element.GroupTime + ")"
-		} else {
-			query += " ORDER BY time " + strings.ToUpper(string(timeDirection))
+		var combined Expr
+		for _, child := range children {
+			expr, err := buildFilterExpression(child, negate)
+			if err != nil {
+				return nil, err
+			}
+			if combined == nil {
+				combined = expr
+			} else {
+				combined = &BinaryExpr{Op: op, LHS: combined, RHS: expr}
+			}
 		}
-		if element.Limit != nil {
-			query += " LIMIT " + strconv.Itoa(*element.Limit)
+		if combined == nil {
+			return nil, ErrInvalidStatement
 		}
+		return combined, nil
+	case expression.Not != nil:
+		return buildFilterExpression(*expression.Not, !negate)
+	case expression.Filter != nil:
+		return buildFilter(*expression.Filter, negate)
+	case expression.TagFilter != nil:
+		return buildTagFilter(*expression.TagFilter, negate)
+	default:
+		return nil, ErrInvalidStatement
 	}
-	return
+}
+
+func buildFill(fill interface{}) (*Fill, error) {
+	switch v := fill.(type) {
+	case string:
+		return &Fill{Option: FillOption(v)}, nil
+	case float64:
+		return &Fill{Option: FillNumber, Value: v}, nil
+	default:
+		return nil, ErrInvalidStatement
+	}
+}
+
+func buildTimeCondition(elementTime model.QueriesRequestElementTime) (Expr, error) {
+	if elementTime.Last != nil {
+		return &BinaryExpr{Op: ">", LHS: timeRef(), RHS: &BinaryExpr{Op: "-", LHS: now(), RHS: &DurationLiteral{Val: *elementTime.Last}}}, nil
+	}
+	if elementTime.Ahead != nil {
+		return and(
+			&BinaryExpr{Op: ">", LHS: timeRef(), RHS: now()},
+			&BinaryExpr{Op: "<", LHS: timeRef(), RHS: &BinaryExpr{Op: "+", LHS: now(), RHS: &DurationLiteral{Val: *elementTime.Ahead}}},
+		), nil
+	}
+	if elementTime.Start == nil || elementTime.End == nil {
+		return nil, ErrInvalidStatement
+	}
+	start, err := time.Parse(time.RFC3339, *elementTime.Start)
+	if err != nil {
+		return nil, err
+	}
+	end, err := time.Parse(time.RFC3339, *elementTime.End)
+	if err != nil {
+		return nil, err
+	}
+	return and(
+		&BinaryExpr{Op: ">", LHS: timeRef(), RHS: &TimeLiteral{Val: start}},
+		&BinaryExpr{Op: "<", LHS: timeRef(), RHS: &TimeLiteral{Val: end}},
+	), nil
 }
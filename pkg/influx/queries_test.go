@@ -0,0 +1,259 @@
+/*
+ *    Copyright 2021 InfAI (CC SES)
+ *
+ *    Licensed under the Apache License, Version 2.0 (the "License");
+ *    you may not use this file except in compliance with the License.
+ *    You may obtain a copy of the License at
+ *
+ *        http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *    Unless required by applicable law or agreed to in writing, software
+ *    distributed under the License is distributed on an "AS IS" BASIS,
+ *    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *    See the License for the specific language governing permissions and
+ *    limitations under the License.
+ */
+
+package influx
+
+import (
+	"github.com/SENERGY-Platform/influx-wrapper/pkg/api/model"
+	"testing"
+	"time"
+)
+
+func TestGenerateQueries(t *testing.T) {
+	last := "1d"
+	start := "2021-01-01T00:00:00Z"
+	end := "2021-01-02T00:00:00Z"
+	groupTime := "1h"
+	mean := "mean"
+	differenceMax := "difference-max"
+	math := "*3,5"
+	limit := 5
+	timezone := "Europe/Berlin"
+
+	t.Run("raw", func(t *testing.T) {
+		actual, err := GenerateQueries([]model.QueriesRequestElement{{
+			Measurement: "m1",
+			Time:        &model.QueriesRequestElementTime{Last: &last},
+			Columns:     []model.QueriesRequestElementColumn{{Name: "c1"}, {Name: "c2", Math: &math}},
+			Filters: &[]model.QueriesRequestElementFilter{
+				{Column: "c3", Type: "!=", Value: "it's"},
+				{Column: "c4", Type: ">", Value: 1.5, Math: &math},
+			},
+			Limit: &limit,
+		}}, model.Asc)
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		expect := "SELECT \"c1\", \"c2\"*3.5 FROM \"m1\" WHERE \"c3\" != 'it\\'s' AND \"c4\"*3.5 > 1.5 AND \"time\" > now()-1d ORDER BY time ASC LIMIT 5"
+		if actual != expect {
+			t.Error("\nexpect\n", expect, "\nactual\n", actual)
+		}
+	})
+
+	t.Run("grouped", func(t *testing.T) {
+		actual, err := GenerateQueries([]model.QueriesRequestElement{
+			{
+				Measurement: "m1",
+				Time:        &model.QueriesRequestElementTime{Start: &start, End: &end},
+				Columns:     []model.QueriesRequestElementColumn{{Name: "c1", GroupType: &mean}, {Name: "c2", GroupType: &differenceMax}},
+				GroupTime:   &groupTime,
+				Fill:        "previous",
+				Timezone:    &timezone,
+			},
+			{
+				Measurement: "m2",
+				Time:        &model.QueriesRequestElementTime{Ahead: &last},
+				Columns:     []model.QueriesRequestElementColumn{{Name: "c1"}},
+			},
+		}, model.Desc)
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		expect := "SELECT mean(\"c1\"), difference(max(\"c2\")) FROM \"m1\" WHERE \"time\" > '2021-01-01T00:00:00Z' AND \"time\" < '2021-01-02T00:00:00Z' GROUP BY time(1h) fill(previous) tz('Europe/Berlin'); " +
+			"SELECT \"c1\" FROM \"m2\" WHERE \"time\" > now() AND \"time\" < now()+1d ORDER BY time DESC"
+		if actual != expect {
+			t.Error("\nexpect\n", expect, "\nactual\n", actual)
+		}
+	})
+
+	t.Run("functions", func(t *testing.T) {
+		percentile := "percentile-99.9"
+		integral := "integral-1h"
+		derivative := "non_negative_derivative-max-1s"
+		movingAverage := "moving_average-3"
+		elapsed := "elapsed-1ms"
+		actual, err := GenerateQueries([]model.QueriesRequestElement{
+			{
+				Measurement: "m1",
+				Columns: []model.QueriesRequestElementColumn{
+					{Name: "c1", GroupType: &percentile},
+					{Name: "c2", GroupType: &integral, Math: &math},
+					{Name: "c3", GroupType: &derivative},
+				},
+				GroupTime: &groupTime,
+			},
+			{
+				Measurement: "m2",
+				Columns:     []model.QueriesRequestElementColumn{{Name: "c1", GroupType: &movingAverage}, {Name: "c2", GroupType: &elapsed}},
+			},
+		}, model.Asc)
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		expect := "SELECT percentile(\"c1\", 99.9), integral(\"c2\", 1h)*3.5, non_negative_derivative(max(\"c3\"), 1s) FROM \"m1\" GROUP BY time(1h); " +
+			"SELECT moving_average(\"c1\", 3), elapsed(\"c2\", 1ms) FROM \"m2\" ORDER BY time ASC"
+		if actual != expect {
+			t.Error("\nexpect\n", expect, "\nactual\n", actual)
+		}
+	})
+
+	t.Run("pagination", func(t *testing.T) {
+		offset := 10
+		cursor := model.Cursor{Time: time.Date(2021, 1, 1, 0, 0, 0, 500, time.UTC), Direction: model.Desc}.Token()
+		actual, err := GenerateQueries([]model.QueriesRequestElement{{
+			Measurement: "m1",
+			Columns:     []model.QueriesRequestElementColumn{{Name: "c1"}},
+			Limit:       &limit,
+			Offset:      &offset,
+		}, {
+			Measurement: "m1",
+			Columns:     []model.QueriesRequestElementColumn{{Name: "c1"}},
+			Limit:       &limit,
+			Cursor:      &cursor,
+		}}, model.Desc)
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		expect := "SELECT \"c1\" FROM \"m1\" ORDER BY time DESC LIMIT 5 OFFSET 10; " +
+			"SELECT \"c1\" FROM \"m1\" WHERE \"time\" < '2021-01-01T00:00:00.0000005Z' ORDER BY time DESC LIMIT 5"
+		if actual != expect {
+			t.Error("\nexpect\n", expect, "\nactual\n", actual)
+		}
+	})
+
+	t.Run("pagination within equal timestamps", func(t *testing.T) {
+		cursor := model.Cursor{Time: time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC), Direction: model.Asc, Skip: 2}.Token()
+		actual, err := GenerateQueries([]model.QueriesRequestElement{{
+			Measurement: "m1",
+			Columns:     []model.QueriesRequestElementColumn{{Name: "c1"}},
+			Limit:       &limit,
+			Cursor:      &cursor,
+		}}, model.Asc)
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		expect := "SELECT \"c1\" FROM \"m1\" WHERE \"time\" >= '2021-01-01T00:00:00Z' ORDER BY time ASC LIMIT 5 OFFSET 2"
+		if actual != expect {
+			t.Error("\nexpect\n", expect, "\nactual\n", actual)
+		}
+	})
+
+	t.Run("tags", func(t *testing.T) {
+		actual, err := GenerateQueries([]model.QueriesRequestElement{{
+			Measurement: "m1",
+			Time:        &model.QueriesRequestElementTime{Last: &last},
+			Columns:     []model.QueriesRequestElementColumn{{Name: "c1", GroupType: &mean}},
+			TagFilters: &[]model.QueriesRequestElementTagFilter{
+				{Key: "building", Type: "=", Value: "b'1"},
+				{Key: "device", Type: "!~", Value: "^test/[0-9]+$"},
+			},
+			GroupTime: &groupTime,
+			GroupTags: []string{"device", "building"},
+			Fill:      -1.5,
+		}}, model.Desc)
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		expect := "SELECT mean(\"c1\") FROM \"m1\" WHERE \"building\"::tag = 'b\\'1' AND \"device\"::tag !~ /^test\\/[0-9]+$/ AND \"time\" > now()-1d GROUP BY time(1h), \"device\", \"building\" fill(-1.5)"
+		if actual != expect {
+			t.Error("\nexpect\n", expect, "\nactual\n", actual)
+		}
+	})
+
+	t.Run("regex ending in backslash", func(t *testing.T) {
+		actual, err := GenerateQueries([]model.QueriesRequestElement{{
+			Measurement: "m1",
+			Columns:     []model.QueriesRequestElementColumn{{Name: "c1"}},
+			TagFilters: &[]model.QueriesRequestElementTagFilter{
+				{Key: "a", Type: "=~", Value: "x\\\\"},
+				{Key: "b", Type: "=~", Value: "; DROP DATABASE \"db\" --"},
+			},
+		}}, model.Desc)
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		expect := "SELECT \"c1\" FROM \"m1\" WHERE \"a\"::tag =~ /x\\x5c/ AND \"b\"::tag =~ /; DROP DATABASE \"db\" --/ ORDER BY time DESC"
+		if actual != expect {
+			t.Error("\nexpect\n", expect, "\nactual\n", actual)
+		}
+		tokens, err := scanInfluxQL(actual)
+		if err != nil || tokens.statements != 1 || len(tokens.regexes) != 2 {
+			t.Error(err, tokens)
+		}
+	})
+
+	t.Run("retention policy", func(t *testing.T) {
+		rp := "one year"
+		actual, err := GenerateQueries([]model.QueriesRequestElement{{
+			Measurement:     "m1",
+			RetentionPolicy: &rp,
+			Time:            &model.QueriesRequestElementTime{Last: &last},
+			Columns:         []model.QueriesRequestElementColumn{{Name: "c1"}},
+		}}, model.Desc)
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		expect := "SELECT \"c1\" FROM \"one year\".\"m1\" WHERE \"time\" > now()-1d ORDER BY time DESC"
+		if actual != expect {
+			t.Error("\nexpect\n", expect, "\nactual\n", actual)
+		}
+	})
+
+	t.Run("filter expression", func(t *testing.T) {
+		actual, err := GenerateQueries([]model.QueriesRequestElement{{
+			Measurement: "m1",
+			Columns:     []model.QueriesRequestElementColumn{{Name: "c1"}},
+			Filters:     &[]model.QueriesRequestElementFilter{{Column: "mode", Type: "!=", Value: "test"}},
+			FilterExpression: &model.QueriesRequestElementFilterExpression{And: []model.QueriesRequestElementFilterExpression{
+				{Or: []model.QueriesRequestElementFilterExpression{
+					{Filter: &model.QueriesRequestElementFilter{Column: "status", Type: "=", Value: "error"}},
+					{Filter: &model.QueriesRequestElementFilter{Column: "temperature", Type: ">", Value: 80.0}},
+				}},
+				{Not: &model.QueriesRequestElementFilterExpression{Or: []model.QueriesRequestElementFilterExpression{
+					{Filter: &model.QueriesRequestElementFilter{Column: "c1", Type: "<", Value: 0.0}},
+					{Not: &model.QueriesRequestElementFilterExpression{TagFilter: &model.QueriesRequestElementTagFilter{Key: "device", Type: "=~", Value: "^d"}}},
+				}}},
+			}},
+		}}, model.Desc)
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		expect := "SELECT \"c1\" FROM \"m1\" WHERE \"mode\" != 'test' AND (\"status\" = 'error' OR \"temperature\" > 80) AND \"c1\" >= 0 AND \"device\"::tag =~ /^d/ ORDER BY time DESC"
+		if actual != expect {
+			t.Error("\nexpect\n", expect, "\nactual\n", actual)
+		}
+	})
+
+	t.Run("invalid filter value", func(t *testing.T) {
+		_, err := GenerateQueries([]model.QueriesRequestElement{{
+			Measurement: "m1",
+			Columns:     []model.QueriesRequestElementColumn{{Name: "c1"}},
+			Filters:     &[]model.QueriesRequestElementFilter{{Column: "c1", Type: "=", Value: []interface{}{"a"}}},
+		}}, model.Desc)
+		if err != ErrInvalidStatement {
+			t.Error("expected ErrInvalidStatement, got", err)
+		}
+	})
+}
@@ -17,67 +17,205 @@
 package influx
 
 import (
+	"context"
+	"github.com/SENERGY-Platform/influx-wrapper/pkg/metrics"
 	influxLib "github.com/orourkedd/influxdb1-client"
+	"github.com/orourkedd/influxdb1-client/models"
 	"log"
 	"net"
 	"strings"
+	"time"
 )
 
-func generateQuery(set uniqueMeasurementsColumns) (query string) {
-	columns := []string{}
-	measurements := []string{}
+func buildLatestValuesQuery(set uniqueMeasurementsColumns) (statement SelectStatement, err error) {
 	for measurement := range set.Measurements {
 		if measurement != "" {
-			measurements = append(measurements, "\""+measurement+"\"")
+			statement.Sources = append(statement.Sources, measurement)
 		}
 	}
 	for columnName, mathOperations := range set.Columns {
 		if columnName != "" {
 			for mathOperation := range mathOperations {
-				part := "\"" + columnName + "\""
+				field := Field{Expr: &VarRef{Name: columnName}}
 				if mathOperation != "" {
-					part += mathOperation + " AS \"" + columnName + mathOperation + "\""
+					field.Expr, err = mathExpr(field.Expr, mathOperation)
+					if err != nil {
+						return statement, err
+					}
+					field.Alias = columnName + mathOperation
 				}
-				columns = append(columns, part)
+				statement.Fields = append(statement.Fields, field)
 			}
 		}
 	}
+	return
+}
 
-	query += "SELECT " + strings.Join(columns, ", ") + " FROM " + strings.Join(measurements, ", ")
-	return query
+// buildLastValuesQuery selects the newest values of a single element, limited by its limit and maxAge. With groupTags,
+// limit applies to each tag combination.
+func buildLastValuesQuery(pair RequestElement) (statement SelectStatement, err error) {
+	field := Field{Expr: &VarRef{Name: pair.ColumnName}}
+	if pair.Math != nil && *pair.Math != "" {
+		field.Expr, err = mathExpr(field.Expr, *pair.Math)
+		if err != nil {
+			return statement, err
+		}
+		field.Alias = getColumnName(pair)
+	}
+	statement.Fields = []Field{field}
+	statement.Sources = []string{pair.Measurement}
+	if pair.RetentionPolicy != nil {
+		statement.RetentionPolicy = *pair.RetentionPolicy
+	}
+	if pair.MaxAge != nil {
+		statement.Condition = &BinaryExpr{Op: ">", LHS: timeRef(), RHS: &BinaryExpr{Op: "-", LHS: now(), RHS: &DurationLiteral{Val: *pair.MaxAge}}}
+	}
+	for _, tag := range pair.GroupTags {
+		statement.GroupBy = append(statement.GroupBy, &VarRef{Name: tag})
+	}
+	statement.OrderBy = OrderDesc
+	statement.Limit = 1
+	if pair.Limit != nil {
+		if *pair.Limit < 1 {
+			return statement, ErrInvalidStatement
+		}
+		statement.Limit = *pair.Limit
+	}
+	return statement, nil
+}
+
+func generateQuery(set uniqueMeasurementsColumns) (query string, err error) {
+	statement, err := buildLatestValuesQuery(set)
+	if err != nil {
+		return "", err
+	}
+	return statement.Render()
 }
 
-func (this *Influx) ExecuteQuery(db string, query string) (responseP *influxLib.Response, err error) {
+func (this *Influx) ExecuteQuery(ctx context.Context, db string, query string) (responseP *influxLib.Response, err error) {
+	start := time.Now()
+	responseP, err = this.executeQuery(ctx, db, query)
+	metrics.ObserveInfluxQuery(time.Since(start))
+	if err != nil {
+		metrics.CountInfluxError(errorClass(err))
+	}
+	return
+}
+
+func errorClass(err error) string {
+	switch err {
+	case ErrInfluxConnection:
+		return metrics.ErrorClassConnection
+	case ErrNotFound:
+		return metrics.ErrorClassNotFound
+	case ErrNULL:
+		return metrics.ErrorClassNULL
+	case ErrTimeout:
+		return metrics.ErrorClassTimeout
+	case context.Canceled:
+		return metrics.ErrorClassCanceled
+	default:
+		return metrics.ErrorClassOther
+	}
+}
+
+func (this *Influx) executeQuery(ctx context.Context, db string, query string) (responseP *influxLib.Response, err error) {
 	if this.config.Debug {
 		log.Println("Query: " + query)
 	}
 
-	responseP, err = this.client.Query(influxLib.Query{
+	responseP, err = this.client.QueryContext(ctx, influxLib.Query{
 		Command:         query,
 		Database:        db,
 		RetentionPolicy: "",
 	})
 	if err != nil {
-		_, isNetError := err.(net.Error)
-		if isNetError {
-			log.Println(err.Error())
-			return responseP, ErrInfluxConnection
-		}
-		return responseP, err
+		return responseP, this.transportError(ctx, err)
 	}
 	if responseP == nil {
 		return responseP, ErrNULL
 	}
 	err = responseP.Error()
 	if err != nil {
-		if strings.Contains(err.Error(), "not found") {
-			if this.config.Debug {
-				log.Println(err.Error())
+		return responseP, this.responseError(err)
+	}
+
+	return
+}
+
+const streamChunkSize = 10000
+
+// StreamQuery executes a single statement as chunked query. handle is called for each chunk of each series, so only a
+// single chunk is held in memory at once.
+func (this *Influx) StreamQuery(ctx context.Context, db string, query string, handle func(series models.Row) error) (err error) {
+	start := time.Now()
+	defer func() {
+		metrics.ObserveInfluxQuery(time.Since(start))
+		if err != nil {
+			metrics.CountInfluxError(errorClass(err))
+		}
+	}()
+	if this.config.Debug {
+		log.Println("Query (chunked): " + query)
+	}
+
+	body, err := this.client.QueryChunked(ctx, influxLib.Query{
+		Command:   query,
+		Database:  db,
+		Chunked:   true,
+		ChunkSize: streamChunkSize,
+	})
+	if err != nil {
+		return this.transportError(ctx, err)
+	}
+	defer body.Close()
+	chunks := influxLib.NewChunkedResponse(body)
+	for {
+		response, err := chunks.NextResponse()
+		if err != nil {
+			return this.transportError(ctx, err)
+		}
+		if response == nil {
+			return nil
+		}
+		err = response.Error()
+		if err != nil {
+			return this.responseError(err)
+		}
+		for _, result := range response.Results {
+			for _, series := range result.Series {
+				err = handle(series)
+				if err != nil {
+					return err
+				}
 			}
-			return responseP, ErrNotFound
 		}
-		return responseP, err
 	}
+}
 
-	return
+// maps errors of requests to InfluxDB
+func (this *Influx) transportError(ctx context.Context, err error) error {
+	if ctx.Err() == context.DeadlineExceeded {
+		return ErrTimeout
+	}
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+	_, isNetError := err.(net.Error)
+	if isNetError {
+		log.Println(err.Error())
+		return ErrInfluxConnection
+	}
+	return err
+}
+
+// maps errors reported by InfluxDB in a response
+func (this *Influx) responseError(err error) error {
+	if strings.Contains(err.Error(), "not found") {
+		if this.config.Debug {
+			log.Println(err.Error())
+		}
+		return ErrNotFound
+	}
+	return err
 }
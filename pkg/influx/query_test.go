@@ -1,6 +1,7 @@
 package influx
 
 import (
+	"context"
 	"errors"
 	"github.com/SENERGY-Platform/influx-wrapper/pkg/configuration"
 	"github.com/SENERGY-Platform/influx-wrapper/pkg/tests/services"
@@ -22,7 +23,11 @@ func TestQuery(t *testing.T) {
 
 	t.Run("generateQuery", func(t *testing.T) {
 		t.Run("empty set", func(t *testing.T) {
-			q := generateQuery(uniqueMeasurementsColumns{})
+			q, err := generateQuery(uniqueMeasurementsColumns{})
+			if err != nil {
+				t.Error(err)
+				return
+			}
 			expect := "SELECT  FROM "
 			if q != expect {
 				t.Error("expect", expect, "actual", q)
@@ -34,10 +39,14 @@ func TestQuery(t *testing.T) {
 			columns := make(map[string]map[string]struct{})
 			columns[""] = make(map[string]struct{})
 			columns[""][""] = struct{}{}
-			q := generateQuery(uniqueMeasurementsColumns{
+			q, err := generateQuery(uniqueMeasurementsColumns{
 				Measurements: measurements,
 				Columns:      columns,
 			})
+			if err != nil {
+				t.Error(err)
+				return
+			}
 			expect := "SELECT  FROM "
 			if q != expect {
 				t.Error("expect", expect, "actual", q)
@@ -49,9 +58,13 @@ func TestQuery(t *testing.T) {
 			columns["c1"][""] = struct{}{}
 			columns["c2"] = make(map[string]struct{})
 			columns["c2"][""] = struct{}{}
-			q := generateQuery(uniqueMeasurementsColumns{
+			q, err := generateQuery(uniqueMeasurementsColumns{
 				Columns: columns,
 			})
+			if err != nil {
+				t.Error(err)
+				return
+			}
 			expect := "SELECT \"c1\", \"c2\" FROM "
 			expectAlt := "SELECT \"c2\", \"c1\" FROM "
 			if q != expect && q != expectAlt {
@@ -62,9 +75,13 @@ func TestQuery(t *testing.T) {
 			measurements := make(map[string]struct{})
 			measurements["m1"] = struct{}{}
 			measurements["m2"] = struct{}{}
-			q := generateQuery(uniqueMeasurementsColumns{
+			q, err := generateQuery(uniqueMeasurementsColumns{
 				Measurements: measurements,
 			})
+			if err != nil {
+				t.Error(err)
+				return
+			}
 			expect := "SELECT  FROM \"m1\", \"m2\""
 			expectAlt := "SELECT  FROM \"m2\", \"m1\""
 			if q != expect && q != expectAlt {
@@ -80,10 +97,14 @@ func TestQuery(t *testing.T) {
 			measurements := make(map[string]struct{})
 			measurements["m1"] = struct{}{}
 			measurements["m2"] = struct{}{}
-			q := generateQuery(uniqueMeasurementsColumns{
+			q, err := generateQuery(uniqueMeasurementsColumns{
 				Columns:      columns,
 				Measurements: measurements,
 			})
+			if err != nil {
+				t.Error(err)
+				return
+			}
 			validResults := []string{}
 			validResults = append(validResults,
 				"SELECT \"c1\", \"c2\" FROM \"m1\", \"m2\"",
@@ -111,10 +132,14 @@ func TestQuery(t *testing.T) {
 			measurements := make(map[string]struct{})
 			measurements["m1"] = struct{}{}
 			measurements["m2"] = struct{}{}
-			q := generateQuery(uniqueMeasurementsColumns{
+			q, err := generateQuery(uniqueMeasurementsColumns{
 				Columns:      columns,
 				Measurements: measurements,
 			})
+			if err != nil {
+				t.Error(err)
+				return
+			}
 			validResults := []string{}
 			validResults = append(validResults,
 				"SELECT \"c1\"+3 AS \"c1+3\", \"c2\"-5 AS \"c2-5\" FROM \"m1\", \"m2\"",
@@ -135,12 +160,60 @@ func TestQuery(t *testing.T) {
 		})
 	})
 
+	t.Run("buildLastValuesQuery", func(t *testing.T) {
+		limit := 10
+		maxAge := "1h"
+		math := "*2"
+		rp := "raw"
+		cases := []struct {
+			name   string
+			pair   RequestElement
+			expect string
+		}{
+			{"default", RequestElement{Measurement: "m1", ColumnName: "c1"}, "SELECT \"c1\" FROM \"m1\" ORDER BY time DESC LIMIT 1"},
+			{"limit", RequestElement{Measurement: "m1", ColumnName: "c1", Limit: &limit}, "SELECT \"c1\" FROM \"m1\" ORDER BY time DESC LIMIT 10"},
+			{"maxAge", RequestElement{Measurement: "m1", ColumnName: "c1", MaxAge: &maxAge}, "SELECT \"c1\" FROM \"m1\" WHERE \"time\" > now()-1h ORDER BY time DESC LIMIT 1"},
+			{"groupTags", RequestElement{Measurement: "m1", ColumnName: "c1", GroupTags: []string{"device", "service"}}, "SELECT \"c1\" FROM \"m1\" GROUP BY \"device\", \"service\" ORDER BY time DESC LIMIT 1"},
+			{"math", RequestElement{Measurement: "m1", ColumnName: "c1", Math: &math, Limit: &limit}, "SELECT \"c1\"*2 AS \"c1*2\" FROM \"m1\" ORDER BY time DESC LIMIT 10"},
+			{"retentionPolicy", RequestElement{Measurement: "m1", ColumnName: "c1", RetentionPolicy: &rp}, "SELECT \"c1\" FROM \"raw\".\"m1\" ORDER BY time DESC LIMIT 1"},
+		}
+		for _, c := range cases {
+			t.Run(c.name, func(t *testing.T) {
+				statement, err := buildLastValuesQuery(c.pair)
+				if err != nil {
+					t.Error(err)
+					return
+				}
+				q, err := statement.Render()
+				if err != nil {
+					t.Error(err)
+					return
+				}
+				if q != c.expect {
+					t.Error("\nexpect\n", c.expect, "\nactual\n", q)
+				}
+			})
+		}
+		t.Run("invalid maxAge", func(t *testing.T) {
+			invalid := "1 hour"
+			statement, err := buildLastValuesQuery(RequestElement{Measurement: "m1", ColumnName: "c1", MaxAge: &invalid})
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			_, err = statement.Render()
+			if err != ErrInvalidStatement {
+				t.Error("expected ErrInvalidStatement, got", err)
+			}
+		})
+	})
+
 	t.Run("executeQuery", func(t *testing.T) {
 		t.Run("net error", func(t *testing.T) {
 			influxClientMock.SetQueryResponse(nil, netError{
 				error: errors.New("net error"),
 			})
-			_, err := influxClient.ExecuteQuery("test", "test")
+			_, err := influxClient.ExecuteQuery(context.Background(), "test", "test")
 			if err != ErrInfluxConnection {
 				t.Fail()
 			}
@@ -148,14 +221,14 @@ func TestQuery(t *testing.T) {
 		t.Run("other err", func(t *testing.T) {
 			testErr := errors.New("other err")
 			influxClientMock.SetQueryResponse(nil, testErr)
-			_, err := influxClient.ExecuteQuery("test", "test")
+			_, err := influxClient.ExecuteQuery(context.Background(), "test", "test")
 			if err != testErr {
 				t.Fail()
 			}
 		})
 		t.Run("response nil", func(t *testing.T) {
 			influxClientMock.SetQueryResponse(nil, nil)
-			_, err := influxClient.ExecuteQuery("test", "test")
+			_, err := influxClient.ExecuteQuery(context.Background(), "test", "test")
 			if err != ErrNULL {
 				t.Fail()
 			}
@@ -164,7 +237,7 @@ func TestQuery(t *testing.T) {
 			influxClientMock.SetQueryResponse(&influxLib.Response{
 				Err: errors.New("DB test not found"),
 			}, nil)
-			_, err := influxClient.ExecuteQuery("test", "test")
+			_, err := influxClient.ExecuteQuery(context.Background(), "test", "test")
 			if err != ErrNotFound {
 				t.Fail()
 			}
@@ -174,11 +247,29 @@ func TestQuery(t *testing.T) {
 			influxClientMock.SetQueryResponse(&influxLib.Response{
 				Err: testErr,
 			}, nil)
-			_, err := influxClient.ExecuteQuery("test", "test")
+			_, err := influxClient.ExecuteQuery(context.Background(), "test", "test")
 			if err != testErr {
 				t.Fail()
 			}
 		})
+		t.Run("deadline exceeded", func(t *testing.T) {
+			influxClientMock.SetQueryResponse(&influxLib.Response{}, nil)
+			ctx, cancel := context.WithTimeout(context.Background(), 0)
+			defer cancel()
+			_, err := influxClient.ExecuteQuery(ctx, "test", "test")
+			if err != ErrTimeout {
+				t.Error("expected ErrTimeout, got", err)
+			}
+		})
+		t.Run("cancelled", func(t *testing.T) {
+			influxClientMock.SetQueryResponse(&influxLib.Response{}, nil)
+			ctx, cancel := context.WithCancel(context.Background())
+			cancel()
+			_, err := influxClient.ExecuteQuery(ctx, "test", "test")
+			if err != context.Canceled {
+				t.Error("expected context.Canceled, got", err)
+			}
+		})
 		t.Run("response normal", func(t *testing.T) {
 			expect := &influxLib.Response{
 				Results: []influxLib.Result{
@@ -192,7 +283,7 @@ func TestQuery(t *testing.T) {
 				},
 			}
 			influxClientMock.SetQueryResponse(expect, nil)
-			actual, err := influxClient.ExecuteQuery("test", "test")
+			actual, err := influxClient.ExecuteQuery(context.Background(), "test", "test")
 			if err != nil {
 				t.Fail()
 			}
@@ -201,4 +292,55 @@ func TestQuery(t *testing.T) {
 			}
 		})
 	})
+	t.Run("StreamQuery", func(t *testing.T) {
+		chunk := func(values ...[]interface{}) *influxLib.Response {
+			return &influxLib.Response{Results: []influxLib.Result{{Series: []models.Row{{Name: "m1", Columns: []string{"time", "c1"}, Values: values}}}}}
+		}
+		t.Run("chunks", func(t *testing.T) {
+			influxClientMock.SetChunkedResponse([]*influxLib.Response{
+				chunk([]interface{}{"2021-01-01T00:00:00Z", 1}, []interface{}{"2021-01-01T00:01:00Z", 2}),
+				chunk([]interface{}{"2021-01-01T00:02:00Z", 3}),
+			}, nil)
+			chunkSizes := []int{}
+			err := influxClient.StreamQuery(context.Background(), "test", "test", func(series models.Row) error {
+				chunkSizes = append(chunkSizes, len(series.Values))
+				return nil
+			})
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			if len(chunkSizes) != 2 || chunkSizes[0] != 2 || chunkSizes[1] != 1 {
+				t.Error(chunkSizes)
+			}
+		})
+		t.Run("not found", func(t *testing.T) {
+			influxClientMock.SetChunkedResponse([]*influxLib.Response{{Err: errors.New("database not found: test")}}, nil)
+			err := influxClient.StreamQuery(context.Background(), "test", "test", func(series models.Row) error {
+				return nil
+			})
+			if err != ErrNotFound {
+				t.Error("expected ErrNotFound, got", err)
+			}
+		})
+		t.Run("net error", func(t *testing.T) {
+			influxClientMock.SetChunkedResponse(nil, netError{error: errors.New("net error")})
+			err := influxClient.StreamQuery(context.Background(), "test", "test", func(series models.Row) error {
+				return nil
+			})
+			if err != ErrInfluxConnection {
+				t.Error("expected ErrInfluxConnection, got", err)
+			}
+		})
+		t.Run("handler error", func(t *testing.T) {
+			testErr := errors.New("handler error")
+			influxClientMock.SetChunkedResponse([]*influxLib.Response{chunk([]interface{}{"2021-01-01T00:00:00Z", 1})}, nil)
+			err := influxClient.StreamQuery(context.Background(), "test", "test", func(series models.Row) error {
+				return testErr
+			})
+			if err != testErr {
+				t.Error("expected handler error, got", err)
+			}
+		})
+	})
 }
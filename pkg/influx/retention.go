@@ -0,0 +1,131 @@
+/*
+ *    Copyright 2021 InfAI (CC SES)
+ *
+ *    Licensed under the Apache License, Version 2.0 (the "License");
+ *    you may not use this file except in compliance with the License.
+ *    You may obtain a copy of the License at
+ *
+ *        http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *    Unless required by applicable law or agreed to in writing, software
+ *    distributed under the License is distributed on an "AS IS" BASIS,
+ *    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *    See the License for the specific language governing permissions and
+ *    limitations under the License.
+ */
+
+package influx
+
+import (
+	"context"
+	"errors"
+	"github.com/SENERGY-Platform/influx-wrapper/pkg/api/model"
+	influxLib "github.com/orourkedd/influxdb1-client"
+	"strings"
+)
+
+// GetRetentionPolicies lists the retention policies of db
+func (this *Influx) GetRetentionPolicies(ctx context.Context, db string) (policies []model.RetentionPolicy, err error) {
+	statement := ShowStatement{Object: ShowRetentionPolicies}
+	query, err := statement.Render()
+	if err != nil {
+		return nil, err
+	}
+	response, err := this.ExecuteQuery(ctx, db, query)
+	if err != nil {
+		return nil, err
+	}
+	if len(response.Results) > 1 {
+		return nil, errors.New("unexpected response length (more than one result)")
+	}
+	policies = []model.RetentionPolicy{}
+	if len(response.Results) == 0 {
+		return policies, nil
+	}
+	return retentionPoliciesFromResult(response.Results[0])
+}
+
+func retentionPoliciesFromResult(result influxLib.Result) (policies []model.RetentionPolicy, err error) {
+	policies = []model.RetentionPolicy{}
+	for _, series := range result.Series {
+		columns := map[string]int{}
+		for i, column := range series.Columns {
+			columns[column] = i
+		}
+		for _, values := range series.Values {
+			policy := model.RetentionPolicy{}
+			ok := true
+			for column, target := range map[string]*string{"name": &policy.Name, "duration": &policy.Duration, "shardGroupDuration": &policy.ShardGroupDuration} {
+				i, found := columns[column]
+				if !found || i >= len(values) {
+					return nil, errors.New("unexpected response (missing column " + column + ")")
+				}
+				*target, ok = values[i].(string)
+				if !ok {
+					return nil, errors.New("unexpected response type (" + column + " not a string)")
+				}
+			}
+			if i, found := columns["default"]; found && i < len(values) {
+				policy.Default, _ = values[i].(bool)
+			}
+			policies = append(policies, policy)
+		}
+	}
+	return policies, nil
+}
+
+// CreateRetentionPolicy creates a retention policy on db. ErrRetentionPolicyExists is returned if InfluxDB reports a
+// different policy with the same name, creating a policy equal to an existing one succeeds. ErrRetentionPolicyLimit is
+// returned if db already has maxCount policies. maxCount is not limited if it is 0.
+// The executed statement is returned, empty if nothing was executed.
+func (this *Influx) CreateRetentionPolicy(ctx context.Context, db string, request model.RetentionPolicyRequest, maxCount int) (query string, err error) {
+	if maxCount > 0 {
+		policies, err := this.GetRetentionPolicies(ctx, db)
+		if err != nil {
+			return "", err
+		}
+		if len(policies) >= maxCount {
+			return "", ErrRetentionPolicyLimit
+		}
+	}
+	statement := retentionPolicyStatement(db, request)
+	statement.Name = request.Name
+	query, err = this.executeRetentionPolicyStatement(ctx, db, statement)
+	if err != nil && strings.Contains(err.Error(), ErrRetentionPolicyExists.Error()) {
+		return query, ErrRetentionPolicyExists
+	}
+	return query, err
+}
+
+// AlterRetentionPolicy alters the retention policy name of db. ErrNotFound is returned if the policy does not exist,
+// ErrRetentionPolicyProtected if it is the autogen policy created by InfluxDB.
+// The executed statement is returned, empty if nothing was executed.
+func (this *Influx) AlterRetentionPolicy(ctx context.Context, db string, name string, request model.RetentionPolicyRequest) (query string, err error) {
+	if name == model.AutogenRetentionPolicy {
+		return "", ErrRetentionPolicyProtected
+	}
+	statement := retentionPolicyStatement(db, request)
+	statement.Name = name
+	statement.Alter = true
+	return this.executeRetentionPolicyStatement(ctx, db, statement)
+}
+
+func retentionPolicyStatement(db string, request model.RetentionPolicyRequest) RetentionPolicyStatement {
+	statement := RetentionPolicyStatement{Database: db, Default: request.Default}
+	if request.Duration != nil {
+		statement.Duration = *request.Duration
+	}
+	if request.ShardDuration != nil {
+		statement.ShardDuration = *request.ShardDuration
+	}
+	return statement
+}
+
+func (this *Influx) executeRetentionPolicyStatement(ctx context.Context, db string, statement RetentionPolicyStatement) (query string, err error) {
+	query, err = statement.Render()
+	if err != nil {
+		return "", err
+	}
+	_, err = this.ExecuteQuery(ctx, db, query)
+	return query, err
+}
@@ -0,0 +1,122 @@
+/*
+ *    Copyright 2021 InfAI (CC SES)
+ *
+ *    Licensed under the Apache License, Version 2.0 (the "License");
+ *    you may not use this file except in compliance with the License.
+ *    You may obtain a copy of the License at
+ *
+ *        http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *    Unless required by applicable law or agreed to in writing, software
+ *    distributed under the License is distributed on an "AS IS" BASIS,
+ *    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *    See the License for the specific language governing permissions and
+ *    limitations under the License.
+ */
+
+package influx
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"github.com/SENERGY-Platform/influx-wrapper/pkg/api/model"
+	"github.com/SENERGY-Platform/influx-wrapper/pkg/configuration"
+	"github.com/SENERGY-Platform/influx-wrapper/pkg/tests/services"
+	influxLib "github.com/orourkedd/influxdb1-client"
+	"github.com/orourkedd/influxdb1-client/models"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestRetentionPolicies(t *testing.T) {
+	influxClientMock := services.NewClientMock()
+	influxClient := Influx{
+		config: &configuration.ConfigStruct{},
+		client: &influxClientMock,
+	}
+	executed := []string{}
+	influxClientMock.SetQueryHandler(func(query influxLib.Query) (*influxLib.Response, error) {
+		executed = append(executed, query.Command)
+		if strings.HasPrefix(query.Command, "CREATE RETENTION POLICY \"raw\"") {
+			return &influxLib.Response{Results: []influxLib.Result{{Err: errors.New("retention policy already exists")}}}, nil
+		}
+		if query.Command != "SHOW RETENTION POLICIES" {
+			return &influxLib.Response{Results: []influxLib.Result{{}}}, nil
+		}
+		return &influxLib.Response{Results: []influxLib.Result{{Series: []models.Row{{
+			Columns: []string{"name", "duration", "shardGroupDuration", "replicaN", "default"},
+			Values: [][]interface{}{
+				{"autogen", "0s", "168h0m0s", json.Number("1"), false},
+				{"raw", "720h0m0s", "24h0m0s", json.Number("1"), true},
+			},
+		}}}}}, nil
+	})
+	duration := "365d"
+
+	t.Run("GetRetentionPolicies", func(t *testing.T) {
+		policies, err := influxClient.GetRetentionPolicies(context.Background(), "db")
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		expect := []model.RetentionPolicy{
+			{Name: "autogen", Duration: "0s", ShardGroupDuration: "168h0m0s"},
+			{Name: "raw", Duration: "720h0m0s", ShardGroupDuration: "24h0m0s", Default: true},
+		}
+		if !reflect.DeepEqual(policies, expect) {
+			t.Error(policies)
+		}
+	})
+	t.Run("CreateRetentionPolicy", func(t *testing.T) {
+		executed = []string{}
+		query, err := influxClient.CreateRetentionPolicy(context.Background(), "db", model.RetentionPolicyRequest{Name: "long", Duration: &duration}, 3)
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		expect := "CREATE RETENTION POLICY \"long\" ON \"db\" DURATION 365d REPLICATION 1"
+		if query != expect || len(executed) != 2 || executed[1] != expect {
+			t.Error(query, executed)
+		}
+	})
+	t.Run("CreateRetentionPolicy unlimited", func(t *testing.T) {
+		executed = []string{}
+		query, err := influxClient.CreateRetentionPolicy(context.Background(), "db", model.RetentionPolicyRequest{Name: "long", Duration: &duration}, 0)
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		// existing policies are not listed, InfluxDB reports conflicts itself
+		if len(executed) != 1 || executed[0] != query {
+			t.Error(query, executed)
+		}
+	})
+	t.Run("CreateRetentionPolicy exists", func(t *testing.T) {
+		query, err := influxClient.CreateRetentionPolicy(context.Background(), "db", model.RetentionPolicyRequest{Name: "raw", Duration: &duration}, 0)
+		if err != ErrRetentionPolicyExists || query != "CREATE RETENTION POLICY \"raw\" ON \"db\" DURATION 365d REPLICATION 1" {
+			t.Error(query, err)
+		}
+	})
+	t.Run("CreateRetentionPolicy limit", func(t *testing.T) {
+		query, err := influxClient.CreateRetentionPolicy(context.Background(), "db", model.RetentionPolicyRequest{Name: "long", Duration: &duration}, 2)
+		if err != ErrRetentionPolicyLimit || query != "" {
+			t.Error(query, err)
+		}
+	})
+	t.Run("AlterRetentionPolicy", func(t *testing.T) {
+		query, err := influxClient.AlterRetentionPolicy(context.Background(), "db", "raw", model.RetentionPolicyRequest{Duration: &duration, Default: true})
+		expect := "ALTER RETENTION POLICY \"raw\" ON \"db\" DURATION 365d DEFAULT"
+		if err != nil || query != expect {
+			t.Error(query, err)
+		}
+	})
+	t.Run("AlterRetentionPolicy autogen", func(t *testing.T) {
+		executed = []string{}
+		query, err := influxClient.AlterRetentionPolicy(context.Background(), "db", "autogen", model.RetentionPolicyRequest{Duration: &duration})
+		if err != ErrRetentionPolicyProtected || query != "" || len(executed) != 0 {
+			t.Error(query, err, executed)
+		}
+	})
+}
@@ -0,0 +1,116 @@
+/*
+ *    Copyright 2021 InfAI (CC SES)
+ *
+ *    Licensed under the Apache License, Version 2.0 (the "License");
+ *    you may not use this file except in compliance with the License.
+ *    You may obtain a copy of the License at
+ *
+ *        http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *    Unless required by applicable law or agreed to in writing, software
+ *    distributed under the License is distributed on an "AS IS" BASIS,
+ *    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *    See the License for the specific language governing permissions and
+ *    limitations under the License.
+ */
+
+package influx
+
+import (
+	"context"
+	"errors"
+	"github.com/SENERGY-Platform/influx-wrapper/pkg/api/model"
+	influxLib "github.com/orourkedd/influxdb1-client"
+	"regexp"
+	"strconv"
+)
+
+// GetMeasurements lists the measurements of db. If regex is not empty, only matching measurements are returned.
+func (this *Influx) GetMeasurements(ctx context.Context, db string, regex string, limit int, offset int) (measurements []string, err error) {
+	statement := ShowStatement{Object: ShowMeasurements, Limit: limit, Offset: offset}
+	if regex != "" {
+		statement.With = &ShowWith{Regex: &RegexLiteral{Val: regex}}
+	}
+	rows, err := this.executeShow(ctx, db, statement, 1)
+	if err != nil {
+		return nil, err
+	}
+	measurements = make([]string, len(rows))
+	for i, row := range rows {
+		measurements[i] = row[0]
+	}
+	return measurements, nil
+}
+
+// GetFieldKeys lists the fields of measurement with their types
+func (this *Influx) GetFieldKeys(ctx context.Context, db string, measurement string, limit int, offset int) (fieldKeys []model.FieldKey, err error) {
+	rows, err := this.executeShow(ctx, db, ShowStatement{Object: ShowFieldKeys, Source: measurement, Limit: limit, Offset: offset}, 2)
+	if err != nil {
+		return nil, err
+	}
+	fieldKeys = make([]model.FieldKey, len(rows))
+	for i, row := range rows {
+		fieldKeys[i] = model.FieldKey{Key: row[0], Type: row[1]}
+	}
+	return fieldKeys, nil
+}
+
+// GetTagKeys lists the tag keys of measurement
+func (this *Influx) GetTagKeys(ctx context.Context, db string, measurement string, limit int, offset int) (tagKeys []string, err error) {
+	rows, err := this.executeShow(ctx, db, ShowStatement{Object: ShowTagKeys, Source: measurement, Limit: limit, Offset: offset}, 1)
+	if err != nil {
+		return nil, err
+	}
+	tagKeys = make([]string, len(rows))
+	for i, row := range rows {
+		tagKeys[i] = row[0]
+	}
+	return tagKeys, nil
+}
+
+// PrefixRegex returns a regular expression matching all strings starting with prefix
+func PrefixRegex(prefix string) string {
+	return "^" + regexp.QuoteMeta(prefix)
+}
+
+// executeShow executes a single SHOW statement and returns the rows of all series, each with the given number of
+// string columns
+func (this *Influx) executeShow(ctx context.Context, db string, statement ShowStatement, columns int) (rows [][]string, err error) {
+	query, err := statement.Render()
+	if err != nil {
+		return nil, err
+	}
+	response, err := this.ExecuteQuery(ctx, db, query)
+	if err != nil {
+		return nil, err
+	}
+	if len(response.Results) > 1 {
+		return nil, errors.New("unexpected response length (more than one result)")
+	}
+	rows = [][]string{}
+	if len(response.Results) == 0 {
+		return rows, nil
+	}
+	return showRows(response.Results[0], columns)
+}
+
+func showRows(result influxLib.Result, columns int) (rows [][]string, err error) {
+	rows = [][]string{}
+	for _, series := range result.Series {
+		for _, values := range series.Values {
+			if len(values) != columns {
+				return nil, errors.New("unexpected response length (not " + strconv.Itoa(columns) + " values per row)")
+			}
+			row := make([]string, columns)
+			for i, value := range values {
+				var ok bool
+				row[i], ok = value.(string)
+				if !ok {
+					return nil, errors.New("unexpected response type (not a string)")
+				}
+			}
+			rows = append(rows, row)
+		}
+	}
+	return rows, nil
+}
@@ -0,0 +1,59 @@
+/*
+ *    Copyright 2021 InfAI (CC SES)
+ *
+ *    Licensed under the Apache License, Version 2.0 (the "License");
+ *    you may not use this file except in compliance with the License.
+ *    You may obtain a copy of the License at
+ *
+ *        http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *    Unless required by applicable law or agreed to in writing, software
+ *    distributed under the License is distributed on an "AS IS" BASIS,
+ *    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *    See the License for the specific language governing permissions and
+ *    limitations under the License.
+ */
+
+package influx
+
+import (
+	"context"
+	"github.com/SENERGY-Platform/influx-wrapper/pkg/configuration"
+	"github.com/SENERGY-Platform/influx-wrapper/pkg/tests/services"
+	influxLib "github.com/orourkedd/influxdb1-client"
+	"github.com/orourkedd/influxdb1-client/models"
+	"testing"
+)
+
+func TestGetMeasurements(t *testing.T) {
+	influxClientMock := services.NewClientMock()
+	influxClient := Influx{
+		config: &configuration.ConfigStruct{},
+		client: &influxClientMock,
+	}
+	query := ""
+	influxClientMock.SetQueryHandler(func(q influxLib.Query) (*influxLib.Response, error) {
+		query = q.Command
+		return &influxLib.Response{Results: []influxLib.Result{{Series: []models.Row{{Columns: []string{"name"}, Values: [][]interface{}{{"m1"}}}}}}}, nil
+	})
+
+	cases := map[string]string{
+		"":                 "SHOW MEASUREMENTS LIMIT 10",
+		"^m":               "SHOW MEASUREMENTS WITH MEASUREMENT =~ /^m/ LIMIT 10",
+		"x\\\\":            "SHOW MEASUREMENTS WITH MEASUREMENT =~ /x\\x5c/ LIMIT 10",
+		PrefixRegex("x\\"): "SHOW MEASUREMENTS WITH MEASUREMENT =~ /^x\\x5c/ LIMIT 10",
+	}
+	for regex, expect := range cases {
+		measurements, err := influxClient.GetMeasurements(context.Background(), "db", regex, 10, 0)
+		if err != nil || len(measurements) != 1 || measurements[0] != "m1" {
+			t.Error(regex, measurements, err)
+		}
+		if query != expect {
+			t.Error("\nexpect\n", expect, "\nactual\n", query)
+		}
+		tokens, err := scanInfluxQL(query)
+		if err != nil || tokens.statements != 1 {
+			t.Error(query, err, tokens)
+		}
+	}
+}
@@ -16,37 +16,202 @@
 
 package influx
 
-import "errors"
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"github.com/SENERGY-Platform/influx-wrapper/pkg/api/model"
+	influxLib "github.com/orourkedd/influxdb1-client"
+	"sort"
+	"strconv"
+	"strings"
+)
 
-func (this *Influx) GetTags(db string, measurement string) (tagMap map[string][]string, err error) {
-	response, err := this.ExecuteQuery(db, "SHOW TAG VALUES FROM \""+measurement+"\" WITH KEY =~ /.*/ ")
+// TagFilter restricts the tag values returned by GetTagValues. All fields are optional.
+type TagFilter struct {
+	Keys []string
+	// ValueRegex is a regular expression the tag values have to match
+	ValueRegex string
+	// Time only considers shards overlapping the time range, so values of series without points in the time range
+	// may be included
+	Time *model.QueriesRequestElementTime
+}
+
+func (this *Influx) GetTags(ctx context.Context, db string, measurement string) (tagMap map[string][]string, err error) {
+	statement := ShowStatement{Object: ShowTagValues, Source: measurement, With: &ShowWith{Regex: &RegexLiteral{Val: ".*"}}}
+	query, err := statement.Render()
 	if err != nil {
 		return nil, err
 	}
-	tagMap = make(map[string][]string)
-	if len(response.Results) == 0 {
-		return tagMap, nil
+	response, err := this.ExecuteQuery(ctx, db, query)
+	if err != nil {
+		return nil, err
 	}
 	if len(response.Results) > 1 {
-		return tagMap, errors.New("unexpected response length (more than one result)")
+		return nil, errors.New("unexpected response length (more than one result)")
 	}
-	if len(response.Results[0].Series) == 0 {
+	tagMap = make(map[string][]string)
+	if len(response.Results) == 0 {
 		return tagMap, nil
 	}
-	if len(response.Results[0].Series) > 1 {
-		return tagMap, errors.New("unexpected response length (more than one series)")
+	rows, err := showRows(response.Results[0], 2)
+	if err != nil {
+		return nil, err
 	}
-	for _, row := range response.Results[0].Series[0].Values {
-		if len(row) != 2 {
-			return nil, errors.New("unexpected response length (not 2 values per row)")
-		}
-		tagKey := row[0].(string)
-		tagValue := row[1].(string)
-		tagArray, ok := tagMap[tagKey]
+	for _, row := range rows {
+		tagArray, ok := tagMap[row[0]]
 		if !ok {
 			tagArray = []string{}
 		}
-		tagMap[tagKey] = append(tagArray, tagValue)
+		tagMap[row[0]] = append(tagArray, row[1])
 	}
 	return tagMap, nil
 }
+
+// GetTagValues returns a page of the tag values of measurement matching filter, sorted by key and value, and the
+// number of matching values of each key across all pages. Each key is counted, even if it has no matching values.
+// The values are counted first, so only the values of the page are read from InfluxDB.
+func (this *Influx) GetTagValues(ctx context.Context, db string, measurement string, filter TagFilter, limit int, offset int) (values []model.TagValue, counts map[string]int, err error) {
+	keys := uniqueSorted(filter.Keys)
+	if len(keys) == 0 {
+		keys, err = this.GetTagKeys(ctx, db, measurement, 0, 0)
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+	values = []model.TagValue{}
+	counts = map[string]int{}
+	if len(keys) == 0 {
+		return values, counts, nil
+	}
+
+	statements := []ShowStatement{}
+	for _, key := range keys {
+		statement, err := tagValuesStatement(ShowTagValuesCardinality, measurement, key, filter)
+		if err != nil {
+			return nil, nil, err
+		}
+		statements = append(statements, statement)
+	}
+	results, err := this.executeShowStatements(ctx, db, statements)
+	if err != nil {
+		return nil, nil, err
+	}
+	for i, result := range results {
+		counts[keys[i]], err = tagValuesCardinality(result)
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+
+	// the page spans the values of consecutive keys
+	statements = []ShowStatement{}
+	skip := offset
+	remaining := limit
+	for _, key := range keys {
+		if remaining <= 0 {
+			break
+		}
+		if skip >= counts[key] {
+			skip -= counts[key]
+			continue
+		}
+		statement, err := tagValuesStatement(ShowTagValues, measurement, key, filter)
+		if err != nil {
+			return nil, nil, err
+		}
+		statement.Offset = skip
+		statement.Limit = counts[key] - skip
+		if statement.Limit > remaining {
+			statement.Limit = remaining
+		}
+		remaining -= statement.Limit
+		skip = 0
+		statements = append(statements, statement)
+	}
+	if len(statements) == 0 {
+		return values, counts, nil
+	}
+	results, err = this.executeShowStatements(ctx, db, statements)
+	if err != nil {
+		return nil, nil, err
+	}
+	for _, result := range results {
+		rows, err := showRows(result, 2)
+		if err != nil {
+			return nil, nil, err
+		}
+		for _, row := range rows {
+			values = append(values, model.TagValue{Key: row[0], Value: row[1]})
+		}
+	}
+	return values, counts, nil
+}
+
+// tagValuesStatement lists or counts the values of a single tag key matching filter
+func tagValuesStatement(object ShowObject, measurement string, key string, filter TagFilter) (statement ShowStatement, err error) {
+	statement = ShowStatement{Object: object, Source: measurement, With: &ShowWith{Names: []string{key}}}
+	var conditions []Expr
+	if filter.ValueRegex != "" {
+		conditions = append(conditions, &BinaryExpr{Op: "=~", LHS: &VarRef{Name: key}, RHS: &RegexLiteral{Val: filter.ValueRegex}})
+	}
+	if filter.Time != nil {
+		timeCondition, err := buildTimeCondition(*filter.Time)
+		if err != nil {
+			return statement, err
+		}
+		conditions = append(conditions, timeCondition)
+	}
+	statement.Condition = and(conditions...)
+	return statement, nil
+}
+
+// executeShowStatements executes all statements at once and returns one result per statement
+func (this *Influx) executeShowStatements(ctx context.Context, db string, statements []ShowStatement) (results []influxLib.Result, err error) {
+	queries := make([]string, len(statements))
+	for i := range statements {
+		queries[i], err = statements[i].Render()
+		if err != nil {
+			return nil, err
+		}
+	}
+	response, err := this.ExecuteQuery(ctx, db, strings.Join(queries, "; "))
+	if err != nil {
+		return nil, err
+	}
+	if len(response.Results) != len(statements) {
+		return nil, errors.New("unexpected response length (not " + strconv.Itoa(len(statements)) + " results)")
+	}
+	return response.Results, nil
+}
+
+func tagValuesCardinality(result influxLib.Result) (int, error) {
+	if len(result.Series) == 0 {
+		return 0, nil
+	}
+	if len(result.Series) > 1 || len(result.Series[0].Values) != 1 || len(result.Series[0].Values[0]) != 1 {
+		return 0, errors.New("unexpected response length (not a single count)")
+	}
+	switch count := result.Series[0].Values[0][0].(type) {
+	case json.Number:
+		i, err := count.Int64()
+		return int(i), err
+	case float64:
+		return int(count), nil
+	default:
+		return 0, errors.New("unexpected response type (count not a number)")
+	}
+}
+
+func uniqueSorted(list []string) []string {
+	unique := []string{}
+	known := map[string]struct{}{}
+	for _, element := range list {
+		if _, ok := known[element]; !ok {
+			known[element] = struct{}{}
+			unique = append(unique, element)
+		}
+	}
+	sort.Strings(unique)
+	return unique
+}
@@ -0,0 +1,159 @@
+/*
+ *    Copyright 2021 InfAI (CC SES)
+ *
+ *    Licensed under the Apache License, Version 2.0 (the "License");
+ *    you may not use this file except in compliance with the License.
+ *    You may obtain a copy of the License at
+ *
+ *        http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *    Unless required by applicable law or agreed to in writing, software
+ *    distributed under the License is distributed on an "AS IS" BASIS,
+ *    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *    See the License for the specific language governing permissions and
+ *    limitations under the License.
+ */
+
+package influx
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/SENERGY-Platform/influx-wrapper/pkg/api/model"
+	"github.com/SENERGY-Platform/influx-wrapper/pkg/configuration"
+	"github.com/SENERGY-Platform/influx-wrapper/pkg/tests/services"
+	influxLib "github.com/orourkedd/influxdb1-client"
+	"github.com/orourkedd/influxdb1-client/models"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestGetTagValues(t *testing.T) {
+	influxClientMock := services.NewClientMock()
+	influxClient := Influx{
+		config: &configuration.ConfigStruct{},
+		client: &influxClientMock,
+	}
+	tagValues := map[string][]string{"device": {"d1", "d2", "d3"}, "service": {"s1", "s2"}}
+	queries := []string{}
+	influxClientMock.SetQueryHandler(func(q influxLib.Query) (*influxLib.Response, error) {
+		queries = append(queries, q.Command)
+		response := &influxLib.Response{}
+		for _, statement := range strings.Split(q.Command, "; ") {
+			switch {
+			case strings.HasPrefix(statement, "SHOW TAG KEYS"):
+				response.Results = append(response.Results, influxLib.Result{Series: []models.Row{{Columns: []string{"tagKey"}, Values: [][]interface{}{{"device"}, {"service"}}}}})
+			case strings.Contains(statement, "CARDINALITY"):
+				key := "device"
+				if strings.Contains(statement, "\"service\"") {
+					key = "service"
+				}
+				response.Results = append(response.Results, influxLib.Result{Series: []models.Row{{Columns: []string{"count"}, Values: [][]interface{}{{json.Number(strconv.Itoa(len(tagValues[key])))}}}}})
+			default:
+				key := "device"
+				if strings.Contains(statement, "\"service\"") {
+					key = "service"
+				}
+				var limit, offset int
+				parts := strings.Fields(statement)
+				for i, part := range parts {
+					if part == "LIMIT" {
+						limit, _ = strconv.Atoi(parts[i+1])
+					}
+					if part == "OFFSET" {
+						offset, _ = strconv.Atoi(parts[i+1])
+					}
+				}
+				row := models.Row{Columns: []string{"key", "value"}}
+				for _, value := range tagValues[key][offset : offset+limit] {
+					row.Values = append(row.Values, []interface{}{key, value})
+				}
+				response.Results = append(response.Results, influxLib.Result{Series: []models.Row{row}})
+			}
+		}
+		return response, nil
+	})
+
+	start := "2021-01-01T00:00:00Z"
+	end := "2021-01-02T00:00:00Z"
+
+	t.Run("page across keys", func(t *testing.T) {
+		queries = []string{}
+		values, counts, err := influxClient.GetTagValues(context.Background(), "db", "m1", TagFilter{}, 2, 2)
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		expect := []model.TagValue{{Key: "device", Value: "d3"}, {Key: "service", Value: "s1"}}
+		if !reflect.DeepEqual(values, expect) {
+			t.Error(values)
+		}
+		if !reflect.DeepEqual(counts, map[string]int{"device": 3, "service": 2}) {
+			t.Error(counts)
+		}
+		expectQueries := []string{
+			"SHOW TAG KEYS FROM \"m1\"",
+			"SHOW TAG VALUES EXACT CARDINALITY FROM \"m1\" WITH KEY = \"device\"; SHOW TAG VALUES EXACT CARDINALITY FROM \"m1\" WITH KEY = \"service\"",
+			"SHOW TAG VALUES FROM \"m1\" WITH KEY = \"device\" LIMIT 1 OFFSET 2; SHOW TAG VALUES FROM \"m1\" WITH KEY = \"service\" LIMIT 1",
+		}
+		if !reflect.DeepEqual(queries, expectQueries) {
+			t.Error(queries)
+		}
+	})
+	t.Run("filtered", func(t *testing.T) {
+		queries = []string{}
+		last := "1d"
+		_, _, err := influxClient.GetTagValues(context.Background(), "db", "m1", TagFilter{
+			Keys:       []string{"service", "service"},
+			ValueRegex: PrefixRegex("s"),
+			Time:       &model.QueriesRequestElementTime{Last: &last},
+		}, 10, 0)
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		expectQueries := []string{
+			"SHOW TAG VALUES EXACT CARDINALITY FROM \"m1\" WITH KEY = \"service\" WHERE \"service\" =~ /^s/ AND \"time\" > now()-1d",
+			"SHOW TAG VALUES FROM \"m1\" WITH KEY = \"service\" WHERE \"service\" =~ /^s/ AND \"time\" > now()-1d LIMIT 2",
+		}
+		if !reflect.DeepEqual(queries, expectQueries) {
+			t.Error(queries)
+		}
+	})
+	t.Run("regex ending in backslash", func(t *testing.T) {
+		for _, regex := range []string{"x\\\\", PrefixRegex("x\\")} {
+			queries = []string{}
+			_, _, err := influxClient.GetTagValues(context.Background(), "db", "m1", TagFilter{
+				Keys:       []string{"device"},
+				ValueRegex: regex,
+				Time:       &model.QueriesRequestElementTime{Start: &start, End: &end},
+			}, 10, 0)
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			if len(queries) != 2 || !strings.Contains(queries[1], "x\\x5c/ AND \"time\" >") {
+				t.Error(queries)
+			}
+			for _, query := range queries {
+				tokens, err := scanInfluxQL(query)
+				if err != nil || tokens.statements != 1 || len(tokens.regexes) != 1 {
+					t.Error(query, err, tokens)
+				}
+			}
+		}
+	})
+	t.Run("beyond end", func(t *testing.T) {
+		queries = []string{}
+		values, counts, err := influxClient.GetTagValues(context.Background(), "db", "m1", TagFilter{Keys: []string{"device"}}, 10, 5)
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		if len(values) != 0 || counts["device"] != 3 || len(queries) != 1 {
+			t.Error(values, counts, queries)
+		}
+	})
+}
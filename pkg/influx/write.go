@@ -0,0 +1,317 @@
+/*
+ *    Copyright 2021 InfAI (CC SES)
+ *
+ *    Licensed under the Apache License, Version 2.0 (the "License");
+ *    you may not use this file except in compliance with the License.
+ *    You may obtain a copy of the License at
+ *
+ *        http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *    Unless required by applicable law or agreed to in writing, software
+ *    distributed under the License is distributed on an "AS IS" BASIS,
+ *    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *    See the License for the specific language governing permissions and
+ *    limitations under the License.
+ */
+
+package influx
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"github.com/SENERGY-Platform/influx-wrapper/pkg/api/model"
+	"github.com/orourkedd/influxdb1-client/models"
+	"math"
+	"strings"
+	"time"
+)
+
+const DefaultWriteBatchSize = 5000
+
+// precisions accepted for line protocol, as used by the InfluxDB write API
+var precisions = map[string]bool{"": true, "n": true, "ns": true, "u": true, "ms": true, "s": true, "m": true, "h": true}
+
+var ErrInvalidPrecision = errors.New("invalid precision, use one of n, u, ms, s, m, h")
+
+func ValidPrecision(precision string) bool {
+	return precisions[precision]
+}
+
+// ParseLine parses a single line of line protocol. Points without timestamp get the time now.
+func ParseLine(line string, precision string, now time.Time) (models.Point, error) {
+	if !ValidPrecision(precision) {
+		return nil, ErrInvalidPrecision
+	}
+	if precision == "ns" {
+		precision = "n"
+	}
+	points, err := models.ParsePointsWithPrecision([]byte(line), now, precision)
+	if err != nil {
+		return nil, err
+	}
+	if len(points) != 1 {
+		return nil, errors.New("expected exactly one point per line")
+	}
+	return points[0], nil
+}
+
+// JSONPoint converts a point of a JSON body. Numbers have to be decoded as json.Number and are converted to float.
+func JSONPoint(point model.WritePoint, now time.Time) (models.Point, error) {
+	t := now
+	if point.Time != nil {
+		var err error
+		t, err = time.Parse(time.RFC3339, *point.Time)
+		if err != nil {
+			return nil, errors.New("time must be formatted as rfc3339")
+		}
+	}
+	fields := models.Fields{}
+	for key, value := range point.Fields {
+		switch v := value.(type) {
+		case json.Number:
+			f, err := v.Float64()
+			if err != nil {
+				return nil, errors.New("field " + key + ": invalid number")
+			}
+			fields[key] = f
+		case float64, string, bool:
+			fields[key] = v
+		default:
+			return nil, errors.New("field " + key + ": value must be a number, string or boolean")
+		}
+	}
+	return models.NewPoint(point.Measurement, models.NewTags(point.Tags), fields, t)
+}
+
+// WritePoints validates points and writes them to db in batches of batchSize. Batches rejected by InfluxDB are split
+// until the rejected points are found. Points that are nil are skipped, they
+// are expected to be reported by the caller. errs holds the error of each point that was not written. Field types are
+// checked against existing fields and earlier points of the same call. If coerce is set, integral floats are written
+// as integer or unsigned if the field has that type.
+// err is only set if no point was written because InfluxDB is not usable.
+func (this *Influx) WritePoints(ctx context.Context, db string, points []models.Point, coerce bool, batchSize int) (errs []error, err error) {
+	errs = make([]error, len(points))
+	if batchSize <= 0 {
+		batchSize = DefaultWriteBatchSize
+	}
+	fieldTypes, err := this.writeFieldTypes(ctx, db, points)
+	if err != nil {
+		return nil, err
+	}
+	accepted := []int{}
+	for i, point := range points {
+		if point == nil {
+			continue
+		}
+		points[i], errs[i] = checkPoint(point, fieldTypes, coerce)
+		if errs[i] == nil {
+			accepted = append(accepted, i)
+		}
+	}
+
+	written := 0
+	for start := 0; start < len(accepted); start += batchSize {
+		end := start + batchSize
+		if end > len(accepted) {
+			end = len(accepted)
+		}
+		batch := accepted[start:end]
+		err = this.writeBatch(ctx, db, points, batch)
+		if err == nil {
+			written += len(batch)
+			continue
+		}
+		if _, rejected := err.(WriteRejectedError); !rejected {
+			// InfluxDB is not usable, so the remaining points are not attempted
+			if written == 0 {
+				return nil, err
+			}
+			for _, i := range accepted[start:] {
+				errs[i] = err
+			}
+			return errs, nil
+		}
+		// valid points of a rejected batch might be written, so writing them again only overwrites them
+		bisected, err := this.writeRejected(ctx, db, points, batch, err, errs)
+		written += bisected
+		if err != nil {
+			for _, i := range accepted[end:] {
+				errs[i] = err
+			}
+			return errs, nil
+		}
+	}
+	return errs, nil
+}
+
+// writeRejected writes the halves of a batch rejected with rejectedErr until the rejected points are isolated, so a
+// batch with few rejected points only needs few writes. errs is set for each point that was not written. Returns the
+// number of written points, err is only set if InfluxDB is not usable.
+func (this *Influx) writeRejected(ctx context.Context, db string, points []models.Point, batch []int, rejectedErr error, errs []error) (written int, err error) {
+	if len(batch) == 1 {
+		errs[batch[0]] = rejectedErr
+		return 0, nil
+	}
+	half := len(batch) / 2
+	for _, part := range [][]int{batch[:half], batch[half:]} {
+		if err != nil {
+			for _, i := range part {
+				errs[i] = err
+			}
+			continue
+		}
+		err = this.writeBatch(ctx, db, points, part)
+		if err == nil {
+			written += len(part)
+			continue
+		}
+		if _, rejected := err.(WriteRejectedError); !rejected {
+			for _, i := range part {
+				errs[i] = err
+			}
+			continue
+		}
+		var partWritten int
+		partWritten, err = this.writeRejected(ctx, db, points, part, err, errs)
+		written += partWritten
+	}
+	return written, err
+}
+
+func (this *Influx) writeBatch(ctx context.Context, db string, points []models.Point, batch []int) error {
+	b := bytes.Buffer{}
+	for _, i := range batch {
+		b.WriteString(points[i].String())
+		b.WriteByte('\n')
+	}
+	err := this.client.Write(ctx, db, b.Bytes())
+	if err != nil {
+		if _, rejected := err.(WriteRejectedError); rejected {
+			return err
+		}
+		return this.transportError(ctx, err)
+	}
+	return nil
+}
+
+// writeFieldTypes returns the types of the existing fields of all measurements of points by measurement and field key
+func (this *Influx) writeFieldTypes(ctx context.Context, db string, points []models.Point) (fieldTypes map[string]map[string]string, err error) {
+	fieldTypes = map[string]map[string]string{}
+	for _, point := range points {
+		if point == nil {
+			continue
+		}
+		measurement := string(point.Name())
+		if _, ok := fieldTypes[measurement]; ok {
+			continue
+		}
+		fieldTypes[measurement] = map[string]string{}
+		fieldKeys, err := this.GetFieldKeys(ctx, db, measurement, 0, 0)
+		if err != nil {
+			return nil, err
+		}
+		for _, fieldKey := range fieldKeys {
+			fieldTypes[measurement][fieldKey.Key] = fieldKey.Type
+		}
+	}
+	return fieldTypes, nil
+}
+
+// checkPoint validates names and field types of point and adds its field types to fieldTypes. Returns the point with
+// coerced fields.
+func checkPoint(point models.Point, fieldTypes map[string]map[string]string, coerce bool) (models.Point, error) {
+	measurement := string(point.Name())
+	if measurement == "" {
+		return nil, errors.New("measurement must not be empty")
+	}
+	for _, tag := range point.Tags() {
+		if err := checkKey("tag", string(tag.Key)); err != nil {
+			return nil, err
+		}
+		if len(tag.Value) == 0 {
+			return nil, errors.New("tag " + string(tag.Key) + ": value must not be empty")
+		}
+	}
+	fields, err := point.Fields()
+	if err != nil {
+		return nil, err
+	}
+	if len(fields) == 0 {
+		return nil, errors.New("at least one field is required")
+	}
+	known := fieldTypes[measurement]
+	coerced := false
+	for key, value := range fields {
+		if err := checkKey("field", key); err != nil {
+			return nil, err
+		}
+		fieldType := fieldTypeOf(value)
+		if coerce && fieldType == "float" {
+			if converted, ok := coerceFloat(value.(float64), known[key]); ok {
+				fields[key] = converted
+				fieldType = known[key]
+				coerced = true
+			}
+		}
+		if existing, ok := known[key]; ok && existing != fieldType {
+			return nil, errors.New("field " + key + ": type conflict, is " + existing + " but got " + fieldType)
+		}
+	}
+	for key, value := range fields {
+		known[key] = fieldTypeOf(value)
+	}
+	if !coerced {
+		return point, nil
+	}
+	return models.NewPoint(measurement, point.Tags(), fields, point.Time())
+}
+
+func checkKey(kind string, key string) error {
+	if key == "" {
+		return errors.New(kind + " keys must not be empty")
+	}
+	if key == "time" {
+		return errors.New(kind + " key time is reserved")
+	}
+	if strings.ContainsAny(key, "\n\r") {
+		return errors.New(kind + " " + key + ": key must not contain line breaks")
+	}
+	return nil
+}
+
+// fieldTypeOf returns the InfluxDB type name of a field value
+func fieldTypeOf(value interface{}) string {
+	switch value.(type) {
+	case float64:
+		return "float"
+	case int64:
+		return "integer"
+	case uint64:
+		return "unsigned"
+	case string:
+		return "string"
+	case bool:
+		return "boolean"
+	default:
+		return ""
+	}
+}
+
+func coerceFloat(value float64, fieldType string) (interface{}, bool) {
+	if value != math.Trunc(value) {
+		return nil, false
+	}
+	switch fieldType {
+	case "integer":
+		if value >= math.MinInt64 && value < math.MaxInt64 {
+			return int64(value), true
+		}
+	case "unsigned":
+		if value >= 0 && value < math.MaxUint64 {
+			return uint64(value), true
+		}
+	}
+	return nil, false
+}
@@ -0,0 +1,235 @@
+/*
+ *    Copyright 2021 InfAI (CC SES)
+ *
+ *    Licensed under the Apache License, Version 2.0 (the "License");
+ *    you may not use this file except in compliance with the License.
+ *    You may obtain a copy of the License at
+ *
+ *        http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *    Unless required by applicable law or agreed to in writing, software
+ *    distributed under the License is distributed on an "AS IS" BASIS,
+ *    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *    See the License for the specific language governing permissions and
+ *    limitations under the License.
+ */
+
+package influx
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"github.com/SENERGY-Platform/influx-wrapper/pkg/api/model"
+	"github.com/SENERGY-Platform/influx-wrapper/pkg/configuration"
+	"github.com/SENERGY-Platform/influx-wrapper/pkg/tests/services"
+	influxLib "github.com/orourkedd/influxdb1-client"
+	"github.com/orourkedd/influxdb1-client/models"
+	"net"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestWrite(t *testing.T) {
+	now := time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	t.Run("ParseLine", func(t *testing.T) {
+		point, err := ParseLine("m1,device=d1 c1=1.5,c2=2i,c3=\"x\" 1609459200", "s", now)
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		expect := "m1,device=d1 c1=1.5,c2=2i,c3=\"x\" 1609459200000000000"
+		if point.String() != expect {
+			t.Error("expect", expect, "actual", point.String())
+		}
+		point, err = ParseLine("m1 c1=1", "", now)
+		if err != nil || !point.Time().Equal(now) {
+			t.Error(point, err)
+		}
+		if _, err = ParseLine("m1 c1=", "", now); err == nil {
+			t.Error("expected error")
+		}
+		if _, err = ParseLine("m1 c1=1", "d", now); err != ErrInvalidPrecision {
+			t.Error("expected ErrInvalidPrecision, got", err)
+		}
+	})
+
+	t.Run("JSONPoint", func(t *testing.T) {
+		timestamp := "2021-01-01T00:00:01Z"
+		point, err := JSONPoint(model.WritePoint{
+			Measurement: "m 1",
+			Tags:        map[string]string{"device": "d1"},
+			Fields:      map[string]interface{}{"c1": json.Number("2"), "c2": "x", "c3": true},
+			Time:        &timestamp,
+		}, now)
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		expect := "m\\ 1,device=d1 c1=2,c2=\"x\",c3=true 1609459201000000000"
+		if point.String() != expect {
+			t.Error("expect", expect, "actual", point.String())
+		}
+		invalid := []model.WritePoint{
+			{Measurement: "m1", Fields: map[string]interface{}{"c1": nil}},
+			{Measurement: "m1", Fields: map[string]interface{}{"c1": []interface{}{}}},
+			{Measurement: "m1", Fields: map[string]interface{}{}},
+			{Measurement: "m1", Fields: map[string]interface{}{"c1": "x"}, Time: new(string)},
+		}
+		for i, p := range invalid {
+			if _, err := JSONPoint(p, now); err == nil {
+				t.Error(i, "expected error")
+			}
+		}
+	})
+
+	t.Run("WritePoints", func(t *testing.T) {
+		influxClientMock := services.NewClientMock()
+		influxClient := Influx{
+			config: &configuration.ConfigStruct{},
+			client: &influxClientMock,
+		}
+		influxClientMock.SetQueryResponse(&influxLib.Response{
+			Results: []influxLib.Result{{Series: []models.Row{{Name: "m1", Columns: []string{"fieldKey", "fieldType"}, Values: [][]interface{}{{"c1", "integer"}}}}}},
+		}, nil)
+		parse := func(lines ...string) (points []models.Point) {
+			for _, line := range lines {
+				point, err := ParseLine(line, "", now)
+				if err != nil {
+					t.Fatal(err)
+				}
+				points = append(points, point)
+			}
+			return points
+		}
+
+		t.Run("validation", func(t *testing.T) {
+			writes := []string{}
+			influxClientMock.SetWriteHandler(func(db string, lines []byte) error {
+				writes = append(writes, string(lines))
+				return nil
+			})
+			defer influxClientMock.SetWriteHandler(nil)
+			points := parse("m1 c1=1i", "m1 c1=1.5", "m1 c2=\"x\"", "m1 c2=1", "m1,time=x c3=1", "m1 c1=2")
+			points = append(points, nil)
+			errs, err := influxClient.WritePoints(context.Background(), "db", points, false, 2)
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			failed := []bool{false, true, false, true, true, true, false}
+			for i := range failed {
+				if (errs[i] != nil) != failed[i] {
+					t.Error(i, errs[i])
+				}
+			}
+			if len(writes) != 1 || writes[0] != "m1 c1=1i 1609459200000000000\nm1 c2=\"x\" 1609459200000000000\n" {
+				t.Error(writes)
+			}
+		})
+		t.Run("coerce", func(t *testing.T) {
+			writes := []string{}
+			influxClientMock.SetWriteHandler(func(db string, lines []byte) error {
+				writes = append(writes, string(lines))
+				return nil
+			})
+			defer influxClientMock.SetWriteHandler(nil)
+			errs, err := influxClient.WritePoints(context.Background(), "db", parse("m1 c1=2", "m1 c1=2.5", "m1 c2=3"), true, 0)
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			if errs[0] != nil || errs[1] == nil || errs[2] != nil {
+				t.Error(errs)
+			}
+			if len(writes) != 1 || writes[0] != "m1 c1=2i 1609459200000000000\nm1 c2=3 1609459200000000000\n" {
+				t.Error(writes)
+			}
+		})
+		t.Run("rejected batch", func(t *testing.T) {
+			influxClientMock.SetWriteHandler(func(db string, lines []byte) error {
+				if strings.Contains(string(lines), "c9") {
+					return WriteRejectedError{Message: "partial write: field type conflict"}
+				}
+				return nil
+			})
+			defer influxClientMock.SetWriteHandler(nil)
+			errs, err := influxClient.WritePoints(context.Background(), "db", parse("m1 c1=1i", "m1 c9=1", "m1 c1=2i"), false, 0)
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			if errs[0] != nil || errs[1] == nil || errs[1].Error() != "partial write: field type conflict" || errs[2] != nil {
+				t.Error(errs)
+			}
+		})
+		t.Run("rejected batch is bisected", func(t *testing.T) {
+			writes := 0
+			influxClientMock.SetWriteHandler(func(db string, lines []byte) error {
+				writes++
+				if strings.Contains(string(lines), "c9") {
+					return WriteRejectedError{Message: "partial write: field type conflict"}
+				}
+				return nil
+			})
+			defer influxClientMock.SetWriteHandler(nil)
+			lines := []string{}
+			for i := 0; i < 16; i++ {
+				lines = append(lines, "m1 c1=1i")
+			}
+			lines[11] = "m1 c9=1"
+			errs, err := influxClient.WritePoints(context.Background(), "db", parse(lines...), false, 0)
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			for i := range errs {
+				if (errs[i] != nil) != (i == 11) {
+					t.Error(i, errs[i])
+				}
+			}
+			// the batch and two halves on each of the four levels
+			if writes != 9 {
+				t.Error("expected 9 writes, got", writes)
+			}
+		})
+		t.Run("connection error while bisecting", func(t *testing.T) {
+			writes := 0
+			influxClientMock.SetWriteHandler(func(db string, lines []byte) error {
+				writes++
+				if writes == 1 {
+					return WriteRejectedError{Message: "partial write: field type conflict"}
+				}
+				if writes == 2 {
+					return nil
+				}
+				return &net.OpError{Op: "dial", Err: errors.New("connection refused")}
+			})
+			defer influxClientMock.SetWriteHandler(nil)
+			errs, err := influxClient.WritePoints(context.Background(), "db", parse("m1 c1=1i", "m1 c1=2i", "m1 c1=3i", "m1 c1=4i", "m1 c1=5i"), false, 4)
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			// the first half is written, the rest fails without further writes
+			if errs[0] != nil || errs[1] != nil || errs[2] != ErrInfluxConnection || errs[3] != ErrInfluxConnection || errs[4] != ErrInfluxConnection {
+				t.Error(errs)
+			}
+			if writes != 3 {
+				t.Error("expected 3 writes, got", writes)
+			}
+		})
+		t.Run("connection error", func(t *testing.T) {
+			influxClientMock.SetWriteHandler(func(db string, lines []byte) error {
+				return &net.OpError{Op: "dial", Err: errors.New("connection refused")}
+			})
+			defer influxClientMock.SetWriteHandler(nil)
+			_, err := influxClient.WritePoints(context.Background(), "db", parse("m1 c1=1i"), false, 0)
+			if err != ErrInfluxConnection {
+				t.Error("expected ErrInfluxConnection, got", err)
+			}
+		})
+	})
+}
@@ -0,0 +1,98 @@
+/*
+ *    Copyright 2021 InfAI (CC SES)
+ *
+ *    Licensed under the Apache License, Version 2.0 (the "License");
+ *    you may not use this file except in compliance with the License.
+ *    You may obtain a copy of the License at
+ *
+ *        http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *    Unless required by applicable law or agreed to in writing, software
+ *    distributed under the License is distributed on an "AS IS" BASIS,
+ *    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *    See the License for the specific language governing permissions and
+ *    limitations under the License.
+ */
+
+package metrics
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"strconv"
+	"time"
+)
+
+const namespace = "influx_wrapper"
+
+var httpRequests = promauto.NewCounterVec(prometheus.CounterOpts{
+	Namespace: namespace,
+	Name:      "http_requests_total",
+	Help:      "Number of handled http requests by route, method and status code.",
+}, []string{"route", "method", "status"})
+
+var httpRequestDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+	Namespace: namespace,
+	Name:      "http_request_duration_seconds",
+	Help:      "Latency of http requests by route, method and status code.",
+	Buckets:   prometheus.DefBuckets,
+}, []string{"route", "method", "status"})
+
+var influxQueryDuration = promauto.NewHistogram(prometheus.HistogramOpts{
+	Namespace: namespace,
+	Name:      "influx_query_duration_seconds",
+	Help:      "Latency of queries sent to InfluxDB.",
+	Buckets:   prometheus.DefBuckets,
+})
+
+var influxQueryErrors = promauto.NewCounterVec(prometheus.CounterOpts{
+	Namespace: namespace,
+	Name:      "influx_query_errors_total",
+	Help:      "Number of failed InfluxDB queries by error class.",
+}, []string{"class"})
+
+var queriesStatements = promauto.NewHistogram(prometheus.HistogramOpts{
+	Namespace: namespace,
+	Name:      "queries_statements",
+	Help:      "Number of statements per /queries call.",
+	Buckets:   prometheus.ExponentialBuckets(1, 2, 8),
+})
+
+var responseRows = promauto.NewHistogramVec(prometheus.HistogramOpts{
+	Namespace: namespace,
+	Name:      "response_rows",
+	Help:      "Number of rows in responses by route.",
+	Buckets:   prometheus.ExponentialBuckets(1, 10, 7),
+}, []string{"route"})
+
+// Error classes of failed InfluxDB queries
+const (
+	ErrorClassConnection = "connection"
+	ErrorClassNotFound   = "not_found"
+	ErrorClassNULL       = "null"
+	ErrorClassTimeout    = "timeout"
+	ErrorClassCanceled   = "canceled"
+	ErrorClassOther      = "other"
+)
+
+func ObserveRequest(route string, method string, status int, duration time.Duration) {
+	statusLabel := strconv.Itoa(status)
+	httpRequests.WithLabelValues(route, method, statusLabel).Inc()
+	httpRequestDuration.WithLabelValues(route, method, statusLabel).Observe(duration.Seconds())
+}
+
+func ObserveInfluxQuery(duration time.Duration) {
+	influxQueryDuration.Observe(duration.Seconds())
+}
+
+func CountInfluxError(class string) {
+	influxQueryErrors.WithLabelValues(class).Inc()
+}
+
+func ObserveQueriesStatements(statements int) {
+	queriesStatements.Observe(float64(statements))
+}
+
+func ObserveResponseRows(route string, rows int) {
+	responseRows.WithLabelValues(route).Observe(float64(rows))
+}
@@ -17,12 +17,25 @@
 package services
 
 import (
+	"bytes"
+	"context"
+	"encoding/json"
 	influxLib "github.com/orourkedd/influxdb1-client"
+	"io"
+	"time"
 )
 
 type ClientMock struct {
 	queryResponse *influxLib.Response
 	queryError    error
+	pingLatency   time.Duration
+	pingVersion   string
+	pingError     error
+	pingHandler   func(ctx context.Context) (time.Duration, string, error)
+	chunks        []*influxLib.Response
+	chunkedError  error
+	queryHandler  func(q influxLib.Query) (*influxLib.Response, error)
+	writeHandler  func(db string, lines []byte) error
 }
 
 func NewClientMock() ClientMock {
@@ -35,6 +48,76 @@ func (c *ClientMock) SetQueryResponse(queryResponse *influxLib.Response, queryEr
 	c.queryResponse = queryResponse
 }
 
-func (c *ClientMock) Query(q influxLib.Query) (*influxLib.Response, error) {
+// SetQueryHandler answers queries depending on the query, overrides SetQueryResponse while set
+func (c *ClientMock) SetQueryHandler(handler func(q influxLib.Query) (*influxLib.Response, error)) {
+	c.queryHandler = handler
+}
+
+// SetWriteHandler is called with each write, writes succeed if no handler is set
+func (c *ClientMock) SetWriteHandler(handler func(db string, lines []byte) error) {
+	c.writeHandler = handler
+}
+
+func (c *ClientMock) Write(ctx context.Context, db string, lines []byte) error {
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+	if c.writeHandler != nil {
+		return c.writeHandler(db, lines)
+	}
+	return nil
+}
+
+func (c *ClientMock) SetPingResponse(latency time.Duration, version string, pingError error) {
+	c.pingLatency = latency
+	c.pingVersion = version
+	c.pingError = pingError
+}
+
+// SetPingHandler is called with each ping, overrides SetPingResponse while set
+func (c *ClientMock) SetPingHandler(handler func(ctx context.Context) (time.Duration, string, error)) {
+	c.pingHandler = handler
+}
+
+func (c *ClientMock) PingContext(ctx context.Context) (time.Duration, string, error) {
+	if ctx.Err() != nil {
+		return 0, "", ctx.Err()
+	}
+	if c.pingHandler != nil {
+		return c.pingHandler(ctx)
+	}
+	return c.pingLatency, c.pingVersion, c.pingError
+}
+
+func (c *ClientMock) SetChunkedResponse(chunks []*influxLib.Response, chunkedError error) {
+	c.chunks = chunks
+	c.chunkedError = chunkedError
+}
+
+// QueryChunked streams all chunks set with SetChunkedResponse as they would be sent by InfluxDB
+func (c *ClientMock) QueryChunked(ctx context.Context, q influxLib.Query) (io.ReadCloser, error) {
+	if ctx.Err() != nil {
+		return nil, ctx.Err()
+	}
+	if c.chunkedError != nil {
+		return nil, c.chunkedError
+	}
+	buf := &bytes.Buffer{}
+	for _, chunk := range c.chunks {
+		err := json.NewEncoder(buf).Encode(chunk)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return io.NopCloser(buf), nil
+}
+
+func (c *ClientMock) QueryContext(ctx context.Context, q influxLib.Query) (*influxLib.Response, error) {
+	if ctx.Err() != nil {
+		return nil, ctx.Err()
+	}
+	if c.queryHandler != nil {
+		return c.queryHandler(q)
+	}
 	return c.queryResponse, c.queryError
 }